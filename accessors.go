@@ -0,0 +1,83 @@
+package shlike
+
+import "reflect"
+import "time"
+
+// Decodes value of variable `name` into `ptr`, if variable is set.
+// Returns a `*DecodeError` if the value is invalid.
+func decodeVariable(c Config, name string, ptr interface{}) error {
+	if value := c.Get(name); value != nil {
+		if err := decodeValue(value, reflect.ValueOf(ptr).Elem()); err != nil {
+			return decodeError(c, name, err)
+		}
+	}
+	return nil
+}
+
+// Returns single word value of variable `name`, or `def` if unset
+func LookupString(c Config, name string, def string) (string, error) {
+	v := def
+	if err := decodeVariable(c, name, &v); err != nil {
+		return def, err
+	}
+	return v, nil
+}
+
+// Returns variable `name` as a decimal integer, or `def` if unset
+func LookupInt(c Config, name string, def int) (int, error) {
+	v := def
+	if err := decodeVariable(c, name, &v); err != nil {
+		return def, err
+	}
+	return v, nil
+}
+
+// Returns variable `name` parsed by `strconv.ParseBool`, or `def` if unset
+func LookupBool(c Config, name string, def bool) (bool, error) {
+	v := def
+	if err := decodeVariable(c, name, &v); err != nil {
+		return def, err
+	}
+	return v, nil
+}
+
+// Returns variable `name` parsed by `time.ParseDuration`, or `def` if unset
+func LookupDuration(c Config, name string, def time.Duration) (time.Duration, error) {
+	v := def
+	if err := decodeVariable(c, name, &v); err != nil {
+		return def, err
+	}
+	return v, nil
+}
+
+// Like `LookupString`, but returns `def` instead of an error
+func GetString(c Config, name string, def string) string {
+	v, _ := LookupString(c, name, def)
+	return v
+}
+
+// Like `LookupInt`, but returns `def` instead of an error
+func GetInt(c Config, name string, def int) int {
+	v, _ := LookupInt(c, name, def)
+	return v
+}
+
+// Like `LookupBool`, but returns `def` instead of an error
+func GetBool(c Config, name string, def bool) bool {
+	v, _ := LookupBool(c, name, def)
+	return v
+}
+
+// Like `LookupDuration`, but returns `def` instead of an error
+func GetDuration(c Config, name string, def time.Duration) time.Duration {
+	v, _ := LookupDuration(c, name, def)
+	return v
+}
+
+// Returns all words of variable `name`, or `def` if unset
+func GetList(c Config, name string, def []string) []string {
+	if v := c.Get(name); v != nil {
+		return v
+	}
+	return def
+}
@@ -0,0 +1,67 @@
+package shlike
+
+import "testing"
+import "time"
+
+import . "github.com/smartystreets/goconvey/convey"
+
+func TestAccessors(t *testing.T) {
+	Convey("Typed accessors", t, func() {
+		cfg := NewConfig()
+		So(cfg.Eval(`
+NAME = 'my app'
+PORT = 6379
+OCTAL = 010
+HEX = 0x10
+DEBUG = yes
+VERBOSE = true
+TIMEOUT = 1m
+HOSTS = a b
+EMPTY =
+`), ShouldBeNil)
+
+		So(GetString(cfg, "NAME", "default"), ShouldEqual, "my app")
+		So(GetString(cfg, "UNSET", "default"), ShouldEqual, "default")
+		So(GetString(cfg, "HOSTS", "default"), ShouldEqual, "default")
+		So(GetInt(cfg, "PORT", 1), ShouldEqual, 6379)
+		So(GetInt(cfg, "NAME", 1), ShouldEqual, 1)
+		So(GetInt(cfg, "OCTAL", 1), ShouldEqual, 10)
+		So(GetInt(cfg, "HEX", 1), ShouldEqual, 1)
+		So(GetBool(cfg, "VERBOSE", false), ShouldBeTrue)
+		So(GetBool(cfg, "DEBUG", false), ShouldBeFalse)
+		So(GetDuration(cfg, "TIMEOUT", time.Second), ShouldEqual, time.Minute)
+		So(GetDuration(cfg, "UNSET", time.Second), ShouldEqual, time.Second)
+		So(GetList(cfg, "HOSTS", nil), ShouldResemble, []string{"a", "b"})
+		So(GetList(cfg, "EMPTY", []string{"x"}), ShouldResemble, []string{})
+		So(GetList(cfg, "UNSET", []string{"x"}), ShouldResemble, []string{"x"})
+
+		v, err := LookupString(cfg, "UNSET", "default")
+		So(err, ShouldBeNil)
+		So(v, ShouldEqual, "default")
+
+		_, err = LookupString(cfg, "HOSTS", "")
+		So(err, ShouldNotBeNil)
+		So(err.Error(), ShouldEqual, "HOSTS: expected a single word, got 2")
+
+		_, err = LookupString(cfg, "EMPTY", "")
+		So(err.Error(), ShouldEqual, "EMPTY: expected a single word, got 0")
+
+		n, err := LookupInt(cfg, "NAME", 7)
+		So(n, ShouldEqual, 7)
+		So(err.Error(), ShouldEqual, `NAME: strconv.ParseInt: parsing "my app": invalid syntax`)
+
+		_, err = LookupBool(cfg, "DEBUG", false)
+		So(err, ShouldHaveSameTypeAs, &DecodeError{})
+
+		d, err := LookupDuration(cfg, "TIMEOUT", 0)
+		So(err, ShouldBeNil)
+		So(d, ShouldEqual, time.Minute)
+	})
+
+	Convey("Typed accessor errors include origin", t, func() {
+		cfg := NewProvenanceConfig(nil)
+		So(EvalInto(cfg, "PORT = http"), ShouldBeNil)
+		_, err := LookupInt(cfg, "PORT", 80)
+		So(err.Error(), ShouldEqual, `(eval):1:1: PORT: strconv.ParseInt: parsing "http": invalid syntax`)
+	})
+}
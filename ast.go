@@ -0,0 +1,249 @@
+package shlike
+
+import "io/ioutil"
+import "strings"
+
+// A node of configuration syntax tree
+type Node interface {
+	Pos() Position // Position of the first byte of the node
+	End() Position // Position just after the node
+}
+
+// Source region of a syntax tree node
+type Span struct {
+	From, To Position
+}
+
+func (s Span) Pos() Position { return s.From }
+func (s Span) End() Position { return s.To }
+
+// A parsed configuration file
+type File struct {
+	Name     string
+	Source   string
+	Stmts    []Stmt
+	Comments []*Comment
+}
+
+// Returns source text of node `n`
+func (f *File) Text(n Node) string {
+	return f.Source[n.Pos().Offset:n.End().Offset]
+}
+
+// A statement: `*AssignStmt`, `*IncludeStmt`, `*ExportStmt`, or
+// `*CommandStmt`
+type Stmt interface {
+	Node
+	stmtNode()
+}
+
+// Variable assignment: `[export] NAME op value...`
+type AssignStmt struct {
+	Span
+	Export   bool
+	Name     string
+	Op       string // One of "=", "+=", "?="
+	Value    []*Word
+	ValuePos Position // Position of value (just after the operator and following white space)
+}
+
+// Dot directive: `. path` or `.? path`
+type IncludeStmt struct {
+	Span
+	Optional bool
+	Words    []*Word
+}
+
+// Export directive: `export NAME...`
+type ExportStmt struct {
+	Span
+	Names []*Word
+}
+
+// A configuration line
+type CommandStmt struct {
+	Span
+	Words []*Word
+}
+
+func (*AssignStmt) stmtNode()  {}
+func (*IncludeStmt) stmtNode() {}
+func (*ExportStmt) stmtNode()  {}
+func (*CommandStmt) stmtNode() {}
+
+// A comment, from `#` to end of line
+type Comment struct {
+	Span
+	Text string
+}
+
+// A word, consisting of parts not separated by white space
+type Word struct {
+	Span
+	Parts []Part
+}
+
+// Returns word's value and true if the word contains no variable
+// references; otherwise, returns empty string and false
+func (w *Word) Literal() (string, bool) {
+	return literalParts(w.Parts)
+}
+
+func literalParts(parts []Part) (string, bool) {
+	var buf strings.Builder
+	for _, part := range parts {
+		switch p := part.(type) {
+		case *Literal:
+			buf.WriteString(p.Value)
+		case *SingleQuoted:
+			buf.WriteString(p.Value)
+		case *DoubleQuoted:
+			if val, ok := literalParts(p.Parts); ok {
+				buf.WriteString(val)
+			} else {
+				return "", false
+			}
+		default:
+			return "", false
+		}
+	}
+	return buf.String(), true
+}
+
+// A part of a word: `*Literal`, `*SingleQuoted`, `*DoubleQuoted`, or
+// `*VarRef`
+type Part interface {
+	Node
+	partNode()
+}
+
+// Bare text, or a backslash-escaped character
+type Literal struct {
+	Span
+	Value string // Unescaped text
+}
+
+// Single quoted string
+type SingleQuoted struct {
+	Span
+	Value string // Text between the quotes
+}
+
+// Double quoted string
+type DoubleQuoted struct {
+	Span
+	Parts []Part // `*Literal` and `*VarRef` parts between the quotes
+}
+
+// Variable reference: `$NAME` or `${...}`
+type VarRef struct {
+	Span
+	Name   string
+	Braced bool
+	Expr   string // Text between the braces
+}
+
+func (*Literal) partNode()      {}
+func (*SingleQuoted) partNode() {}
+func (*DoubleQuoted) partNode() {}
+func (*VarRef) partNode()       {}
+
+// Parses configuration `source` into a syntax tree, without
+// evaluating it. `name` is used in positions.
+func Parse(name, source string) (*File, error) {
+	l := newLexer(nil, name, source, newOptions(nil))
+	l.tree = &syntaxTree{file: &File{Name: name, Source: source}}
+	if err := l.parse(); err != nil {
+		return nil, err
+	}
+	return l.tree.file, nil
+}
+
+// Parses configuration file at `path` into a syntax tree, without
+// evaluating it
+func ParseFile(path string) (*File, error) {
+	source, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	return Parse(path, string(source))
+}
+
+// Syntax tree being built by the lexer
+type syntaxTree struct {
+	file    *File
+	parts   []Part        // Parts of current word
+	quoted  *DoubleQuoted // Open double quoted string
+	words   []*Word       // Words of current statement
+	headEnd int           // Offset just after statement's head (assignment operator, dot, export)
+}
+
+func (l *lexer) span(start, end int) Span {
+	return Span{l.position(start), l.position(end)}
+}
+
+// Adds `part` to current word
+func (l *lexer) addPart(part Part) {
+	if t := l.tree; t.quoted != nil {
+		t.quoted.Parts = append(t.quoted.Parts, part)
+	} else {
+		t.parts = append(t.parts, part)
+	}
+}
+
+func (l *lexer) openQuote(start int) {
+	l.tree.quoted = &DoubleQuoted{Span: l.span(start, start)}
+}
+
+func (l *lexer) closeQuote(end int) {
+	t := l.tree
+	t.quoted.To = l.position(end)
+	t.parts = append(t.parts, t.quoted)
+	t.quoted = nil
+}
+
+func (l *lexer) endSyntaxWord() {
+	if t := l.tree; len(t.parts) > 0 {
+		t.words = append(t.words, &Word{
+			Span:  Span{t.parts[0].Pos(), t.parts[len(t.parts)-1].End()},
+			Parts: t.parts,
+		})
+		t.parts = nil
+	}
+}
+
+func (l *lexer) endSyntaxLine() {
+	t := l.tree
+	l.endSyntaxWord()
+	span := l.span(l.stmt, t.headEnd)
+	if len(t.words) > 0 {
+		span.To = t.words[len(t.words)-1].End()
+	}
+
+	var stmt Stmt
+	switch l.op {
+	case opLine:
+		if len(t.words) > 0 {
+			stmt = &CommandStmt{span, t.words}
+		}
+	case opSet, opAppend, opSetIfUnset:
+		op := map[opKind]string{opSet: "=", opAppend: "+=", opSetIfUnset: "?="}[l.op]
+		stmt = &AssignStmt{span, l.export, l.target, op, t.words, l.position(t.headEnd)}
+	case opDot:
+		stmt = &IncludeStmt{span, l.optional, t.words}
+	case opExport:
+		stmt = &ExportStmt{span, t.words}
+	}
+	if stmt != nil {
+		t.file.Stmts = append(t.file.Stmts, stmt)
+	}
+	t.words = nil
+}
+
+func (l *lexer) addComment(start int, region string) {
+	text := region
+	if i := strings.IndexAny(text, "\r\n"); i >= 0 {
+		text = text[:i]
+	}
+	l.tree.file.Comments = append(l.tree.file.Comments, &Comment{l.span(start, start+len(text)), text})
+}
@@ -0,0 +1,126 @@
+package shlike
+
+import "testing"
+
+import . "github.com/smartystreets/goconvey/convey"
+
+func TestParse(t *testing.T) {
+	Convey("Parse", t, func() {
+		src := `# leading comment
+export FOO += foo\ bar 'baz quux' # trailing
+.? "$DIR/${NAME:-db}.conf"
+run x"a$FOO"y \
+    ""
+BAR =
+export FOO BAR
+`
+		file, err := Parse("test.conf", src)
+		So(err, ShouldBeNil)
+		So(file.Name, ShouldEqual, "test.conf")
+		So(file.Stmts, ShouldHaveLength, 5)
+
+		Convey("Comments", func() {
+			So(file.Comments, ShouldHaveLength, 2)
+			So(file.Comments[0].Text, ShouldEqual, "# leading comment")
+			So(file.Comments[0].Pos(), ShouldResemble, Position{"test.conf", 1, 1, 0})
+			So(file.Comments[1].Text, ShouldEqual, "# trailing")
+			So(file.Text(file.Comments[1]), ShouldEqual, "# trailing")
+		})
+
+		Convey("Assignment", func() {
+			stmt, ok := file.Stmts[0].(*AssignStmt)
+			So(ok, ShouldBeTrue)
+			So(stmt.Export, ShouldBeTrue)
+			So(stmt.Name, ShouldEqual, "FOO")
+			So(stmt.Op, ShouldEqual, "+=")
+			So(stmt.Pos(), ShouldResemble, Position{"test.conf", 2, 1, 18})
+			So(stmt.ValuePos, ShouldResemble, Position{"test.conf", 2, 15, 32})
+			So(file.Text(stmt), ShouldEqual, `export FOO += foo\ bar 'baz quux'`)
+			So(stmt.Value, ShouldHaveLength, 2)
+
+			word := stmt.Value[0]
+			So(file.Text(word), ShouldEqual, `foo\ bar`)
+			So(word.Parts, ShouldResemble, []Part{
+				&Literal{Span{Position{"test.conf", 2, 15, 32}, Position{"test.conf", 2, 18, 35}}, "foo"},
+				&Literal{Span{Position{"test.conf", 2, 18, 35}, Position{"test.conf", 2, 20, 37}}, " "},
+				&Literal{Span{Position{"test.conf", 2, 20, 37}, Position{"test.conf", 2, 23, 40}}, "bar"},
+			})
+			val, ok := word.Literal()
+			So(ok, ShouldBeTrue)
+			So(val, ShouldEqual, "foo bar")
+
+			So(stmt.Value[1].Parts, ShouldHaveLength, 1)
+			So(stmt.Value[1].Parts[0], ShouldHaveSameTypeAs, &SingleQuoted{})
+			val, _ = stmt.Value[1].Literal()
+			So(val, ShouldEqual, "baz quux")
+
+			empty := file.Stmts[3].(*AssignStmt)
+			So(empty.Name, ShouldEqual, "BAR")
+			So(empty.Value, ShouldBeEmpty)
+			So(file.Text(empty), ShouldEqual, "BAR =")
+		})
+
+		Convey("Include", func() {
+			stmt, ok := file.Stmts[1].(*IncludeStmt)
+			So(ok, ShouldBeTrue)
+			So(stmt.Optional, ShouldBeTrue)
+			So(stmt.Words, ShouldHaveLength, 1)
+			So(file.Text(stmt.Words[0]), ShouldEqual, `"$DIR/${NAME:-db}.conf"`)
+
+			dq := stmt.Words[0].Parts[0].(*DoubleQuoted)
+			So(dq.Parts, ShouldHaveLength, 4)
+			So(dq.Parts[0], ShouldResemble, &VarRef{Span{Position{"test.conf", 3, 5, 67}, Position{"test.conf", 3, 9, 71}}, "DIR", false, ""})
+			So(dq.Parts[2], ShouldResemble, &VarRef{Span{Position{"test.conf", 3, 10, 72}, Position{"test.conf", 3, 21, 83}}, "NAME", true, "NAME:-db"})
+			So(file.Text(dq.Parts[3]), ShouldEqual, ".conf")
+
+			_, ok = stmt.Words[0].Literal()
+			So(ok, ShouldBeFalse)
+		})
+
+		Convey("Command", func() {
+			stmt, ok := file.Stmts[2].(*CommandStmt)
+			So(ok, ShouldBeTrue)
+			So(file.Text(stmt), ShouldEqual, "run x\"a$FOO\"y \\\n    \"\"")
+			So(stmt.Words, ShouldHaveLength, 3)
+			So(stmt.Words[1].Parts, ShouldHaveLength, 3)
+			So(file.Text(stmt.Words[1].Parts[1]), ShouldEqual, `"a$FOO"`)
+			So(stmt.Words[2].Pos().Line, ShouldEqual, 5)
+			val, ok := stmt.Words[2].Literal()
+			So(ok, ShouldBeTrue)
+			So(val, ShouldEqual, "")
+		})
+
+		Convey("Export", func() {
+			stmt, ok := file.Stmts[4].(*ExportStmt)
+			So(ok, ShouldBeTrue)
+			So(stmt.Names, ShouldHaveLength, 2)
+			So(file.Text(stmt.Names[1]), ShouldEqual, "BAR")
+		})
+	})
+
+	Convey("Parse does not evaluate", t, func() {
+		file, err := Parse("test.conf", ". nonexistent.conf\nFOO = ${UNDEFINED:?required}\n")
+		So(err, ShouldBeNil)
+		So(file.Stmts, ShouldHaveLength, 2)
+	})
+
+	Convey("Parse reports syntax errors", t, func() {
+		_, err := Parse("test.conf", "FOO = \"unclosed\n")
+		So(err, ShouldNotBeNil)
+		So(err.(*ParseError).Kind, ShouldEqual, ErrUnclosedDoubleQuote)
+
+		_, err = Parse("test.conf", "FOO = ${FOO:}\n")
+		So(err, ShouldNotBeNil)
+		So(err.(*ParseError).Kind, ShouldEqual, ErrInvalidVariableReference)
+	})
+
+	Convey("ParseFile", t, func() {
+		file, err := ParseFile("fixtures/undefined.conf")
+		So(err, ShouldBeNil)
+		So(file.Name, ShouldEqual, "fixtures/undefined.conf")
+		So(file.Stmts, ShouldHaveLength, 1)
+
+		_, err = ParseFile("fixtures/nonexistent.conf")
+		So(err, ShouldNotBeNil)
+	})
+}
@@ -6,7 +6,8 @@
 package shlike
 
 import "fmt"
-import "io/ioutil"
+import "io"
+import "io/fs"
 import "sort"
 import "strings"
 
@@ -25,18 +26,69 @@ type Config interface {
 	Iter() <-chan []string                // Iterator over lines
 }
 
+// A configuration object that keeps track of exported variables,
+// marked with the `export` directive
+type Exporter interface {
+	Export(names ...string) // Mark variables as exported
+	Exported() []string     // List of exported variable names
+}
+
+// Returns `c` as an `Exporter`, and false if it does not support
+// exporting. A `ProvenanceConfig` supports exporting only if the
+// configuration it wraps does.
+func asExporter(c Config) (Exporter, bool) {
+	if p, ok := c.(*ProvenanceConfig); ok {
+		if _, ok := asExporter(p.Config); !ok {
+			return nil, false
+		}
+	}
+	exp, ok := c.(Exporter)
+	return exp, ok
+}
+
 // Evaluates configuration string `source` into `cfg`. Wrapped by Convenience.Eval()
-func EvalInto(cfg Config, source string) error {
-	return newLexer(cfg, "(eval)", source).parse()
+func EvalInto(cfg Config, source string, opts ...Option) error {
+	o := newOptions(opts)
+	l := newLexer(cfg, "(eval)", source, o)
+	l.dir = o.basedir
+	return l.parse()
 }
 
 // Loads configuration file at `path` into `cfg`. Wrapped by Convenience.Load()
-func LoadInto(cfg Config, path string) error {
-	config, err := ioutil.ReadFile(path)
+func LoadInto(cfg Config, path string, opts ...Option) error {
+	return loadInto(cfg, path, newOptions(opts), nil)
+}
+
+// Loads configuration file at `path` within `fsys` into `cfg`.
+// Included files are also read from `fsys`; absolute include paths
+// are relative to root of `fsys`.
+func LoadFS(cfg Config, fsys fs.FS, path string, opts ...Option) error {
+	o := newOptions(opts)
+	o.fsys = fsys
+	return loadInto(cfg, path, o, nil)
+}
+
+// Loads file at `path`, included by `parent` (nil for top level file)
+func loadInto(cfg Config, path string, o *options, parent *lexer) error {
+	f, err := o.open(path)
 	if err != nil {
 		return err
 	}
-	return newLexer(cfg, path, string(config)).parse()
+	defer f.Close()
+	if o.loaded != nil {
+		o.loaded(path)
+	}
+	l := newReaderLexer(cfg, path, f, o)
+	l.parent = parent
+	l.key = o.fileKey(path)
+	return l.parse()
+}
+
+// Loads configuration from `r` into `cfg`. The input is read
+// incrementally. `name` is used in error messages, and to resolve
+// relative paths of included files.
+func LoadReader(cfg Config, name string, r io.Reader, opts ...Option) error {
+	return newReaderLexer(cfg, name, r, newOptions(opts)).parse()
 }
 
 // Returns config serialized as an `EvalInto`-able configuration
@@ -45,14 +97,36 @@ func Serialize(c Config) string {
 	vars := c.Variables()
 	sort.Strings(vars)
 
-	pieces := make([]string, 0, len(vars)+c.Length())
+	exported := map[string]bool{}
+	if exp, ok := asExporter(c); ok {
+		for _, name := range exp.Exported() {
+			exported[name] = true
+		}
+	}
+
+	pieces := make([]string, 0, len(vars)+c.Length()+1)
 
 	for _, v := range vars {
-		pieces = append(pieces, fmt.Sprintf("%s = %s", v, EscapeLine(c.Get(v))))
+		if exported[v] {
+			pieces = append(pieces, fmt.Sprintf("export %s = %s", v, EscapeLine(c.Get(v))))
+			delete(exported, v)
+		} else {
+			pieces = append(pieces, fmt.Sprintf("%s = %s", v, EscapeLine(c.Get(v))))
+		}
+	}
+
+	if len(exported) > 0 {
+		// Exported, but not set
+		names := make([]string, 0, len(exported))
+		for name := range exported {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		pieces = append(pieces, "export "+strings.Join(names, " "))
 	}
 
 	for ln := range c.Iter() {
-		pieces = append(pieces, EscapeLine(ln))
+		pieces = append(pieces, escapeCommand(ln))
 	}
 	return strings.Join(pieces, "\n")
 }
@@ -1,7 +1,15 @@
 package shlike
 
+import "bytes"
+import "errors"
+import "io"
+import "io/fs"
+import "io/ioutil"
 import "os"
+import "strings"
 import "testing"
+import "testing/fstest"
+import "testing/iotest"
 import . "github.com/smartystreets/goconvey/convey"
 
 func TestConfig(t *testing.T) {
@@ -41,6 +49,37 @@ func TestConfig(t *testing.T) {
 			})
 		})
 
+		Convey("Exports", func() {
+			cfg.Set("FOO", "foo", "bar")
+			cfg.Set("BAR", "baz")
+			cfg.Set("QUUX")
+			cfg.Export("FOO", "QUUX", "XYZZY")
+			So(cfg.Exported(), ShouldResemble, []string{"FOO", "QUUX", "XYZZY"})
+
+			Convey("Unsetting unexports", func() {
+				cfg.Unset("FOO")
+				So(cfg.Exported(), ShouldResemble, []string{"QUUX", "XYZZY"})
+			})
+
+			Convey("Environment", func() {
+				So(Environ(cfg, " "), ShouldResemble, []string{"FOO=foo bar", "QUUX="})
+				So(Environ(cfg, ":"), ShouldResemble, []string{"FOO=foo:bar", "QUUX="})
+				So(Environ(struct{ Config }{cfg}, ","), ShouldResemble, []string{"BAR=baz", "FOO=foo,bar", "QUUX="})
+
+				t.Setenv("FOO", "")
+				So(Setenv(cfg, " "), ShouldBeNil)
+				So(os.Getenv("FOO"), ShouldEqual, "foo bar")
+			})
+
+			Convey("Serialization", func() {
+				So(cfg.Serialize(), ShouldEqual, "BAR = baz\nexport FOO = foo bar\nexport QUUX = \nexport XYZZY")
+				reloaded := NewConfig()
+				So(reloaded.Eval(cfg.Serialize()), ShouldBeNil)
+				So(reloaded.Exports, ShouldResemble, cfg.Exports)
+				So(reloaded.Vars, ShouldResemble, cfg.Vars)
+			})
+		})
+
 		Convey("Line access", func() {
 			cfg.Eval("foo\nbar\nbaz")
 			So(cfg.Line(0), ShouldResemble, []string{"foo"})
@@ -58,6 +97,194 @@ func TestConfig(t *testing.T) {
 			})
 		})
 
+		Convey("LoadReader", func() {
+			example, _ := ioutil.ReadFile("fixtures/example.conf")
+			expected := NewConfig()
+			So(expected.Eval(string(example)), ShouldBeNil)
+
+			So(LoadReader(cfg, "fixtures/example.conf", iotest.OneByteReader(bytes.NewReader(example))), ShouldBeNil)
+			So(cfg.Vars, ShouldResemble, expected.Vars)
+			So(cfg.Lines, ShouldResemble, expected.Lines)
+
+			Convey("Includes are relative to name", func() {
+				cfg = NewConfig()
+				So(LoadReader(cfg, "fixtures/stdin", strings.NewReader(". example.conf")), ShouldBeNil)
+				So(cfg.Vars, ShouldResemble, expected.Vars)
+			})
+
+			Convey("Error positions", func() {
+				var warnings []Warning
+				err := LoadReader(cfg, "(stdin)", strings.NewReader("foo\n\nbar 'baz\nquux'\n  $UNDEF\n\"xyzzy\n\n"), CollectWarnings(&warnings))
+				So(warnings, ShouldResemble, []Warning{{Position{"(stdin)", 5, 3, 22}, WarnUndefinedVariable, `Undefined variable "UNDEF"`}})
+				perr := err.(*ParseError)
+				So(perr.Kind, ShouldEqual, ErrUnclosedDoubleQuote)
+				So(perr.Position, ShouldResemble, Position{"(stdin)", 6, 1, 29})
+				So(perr.Snippet, ShouldEqual, "\"xyzzy")
+			})
+
+			Convey("Unclosed single quote", func() {
+				err := LoadReader(cfg, "(stdin)", strings.NewReader("foo\nbar 'baz\n"+strings.Repeat("quux\n", 10000)))
+				perr := err.(*ParseError)
+				So(perr.Kind, ShouldEqual, ErrSyntax)
+				So(perr.Position, ShouldResemble, Position{"(stdin)", 2, 5, 8})
+				So(perr.Snippet, ShouldEqual, "'baz")
+			})
+
+			Convey("Statement head across reader's buffer", func() {
+				for _, indent := range []int{4093, 4094, 4095, 5000} {
+					src := strings.Repeat(" ", indent) + "FOO = x\nrun $FOO\n"
+					read, evaluated := NewConfig(), NewConfig()
+					So(LoadReader(read, "(stdin)", strings.NewReader(src)), ShouldBeNil)
+					So(evaluated.Eval(src), ShouldBeNil)
+					So(read, ShouldResemble, evaluated)
+					So(read.Get("FOO"), ShouldResemble, []string{"x"})
+				}
+			})
+
+			Convey("Read error", func() {
+				So(LoadReader(cfg, "(err)", iotest.ErrReader(io.ErrUnexpectedEOF)), ShouldEqual, io.ErrUnexpectedEOF)
+			})
+		})
+
+		Convey("LoadFS", func() {
+			fsys := fstest.MapFS{
+				"app.conf":           {Data: []byte("A = a\n. conf.d/db.conf\nrun $A $DB")},
+				"conf.d/db.conf":     {Data: []byte("DB = db\n. ../common/log.conf\n. /common/root.conf")},
+				"common/log.conf":    {Data: []byte("LOG = log")},
+				"common/root.conf":   {Data: []byte("ROOT = root")},
+				"broken.conf":        {Data: []byte(". common/missing.conf")},
+				"broken-nested.conf": {Data: []byte(". conf.d/broken.conf")},
+				"conf.d/broken.conf": {Data: []byte("\n'unclosed")},
+			}
+			So(LoadFS(cfg, fsys, "app.conf"), ShouldBeNil)
+			So(cfg.Vars, ShouldResemble, map[string][]string{
+				"A": {"a"}, "DB": {"db"}, "LOG": {"log"}, "ROOT": {"root"},
+			})
+			So(cfg.Lines, ShouldResemble, [][]string{{"run", "a", "db"}})
+
+			perr := LoadFS(cfg, fsys, "broken.conf").(*ParseError)
+			So(perr.Kind, ShouldEqual, ErrInclude)
+			So(errors.Is(perr, fs.ErrNotExist), ShouldBeTrue)
+
+			perr = LoadFS(cfg, fsys, "broken-nested.conf").(*ParseError)
+			So(perr.Kind, ShouldEqual, ErrSyntax)
+			So(perr.Position, ShouldResemble, Position{"conf.d/broken.conf", 2, 1, 1})
+
+			So(LoadFS(cfg, fsys, "nonexistent.conf"), ShouldNotBeNil)
+		})
+
+		Convey("Glob and optional includes", func() {
+			fsys := fstest.MapFS{
+				"app.conf":            {Data: []byte("A = a\n. conf.d/*.conf\n.? local.conf\n.? local.d/*.conf")},
+				"conf.d/20-b.conf":    {Data: []byte("A += b")},
+				"conf.d/10-c.conf":    {Data: []byte("A += c")},
+				"conf.d/30-d.conf":    {Data: []byte("A += d")},
+				"conf.d/README":       {Data: []byte("'not loaded")},
+				"negated.conf":        {Data: []byte("A = a\n. conf.d/[!2]*.conf")},
+				"empty.conf":          {Data: []byte(". empty.d/*.conf")},
+				"bad-pattern.conf":    {Data: []byte(". [.conf")},
+				"nested-error.conf":   {Data: []byte(".? missing-nested.conf")},
+				"missing-nested.conf": {Data: []byte(". missing.conf")},
+			}
+			So(LoadFS(cfg, fsys, "app.conf"), ShouldBeNil)
+			So(cfg.Get("A"), ShouldResemble, []string{"a", "c", "b", "d"})
+
+			So(LoadFS(cfg, fsys, "negated.conf"), ShouldBeNil)
+			So(cfg.Get("A"), ShouldResemble, []string{"a", "c", "d"})
+			So(negateClasses(`a[!b]\[!c[[!]`), ShouldEqual, `a[^b]\[!c[[!]`)
+
+			fsys["local.conf"] = &fstest.MapFile{Data: []byte("A = local")}
+			So(LoadFS(cfg, fsys, "app.conf"), ShouldBeNil)
+			So(cfg.Get("A"), ShouldResemble, []string{"local"})
+
+			perr := LoadFS(cfg, fsys, "empty.conf").(*ParseError)
+			So(perr.Kind, ShouldEqual, ErrInclude)
+			So(perr.Message, ShouldEqual, `No files match "empty.d/*.conf"`)
+
+			So(LoadFS(cfg, fsys, "bad-pattern.conf").(*ParseError).Kind, ShouldEqual, ErrInclude)
+
+			// Optional include only skips the missing file itself
+			perr = LoadFS(cfg, fsys, "nested-error.conf").(*ParseError)
+			So(perr.Kind, ShouldEqual, ErrInclude)
+			So(perr.File, ShouldEqual, "missing-nested.conf")
+
+			So(cfg.Eval(".? fixtures/nonexistent.conf"), ShouldBeNil)
+
+			Convey("Directory names are not patterns", func() {
+				fsys := fstest.MapFS{
+					"a[1]/app.conf":      {Data: []byte(". plain.conf\n. conf.d/*.conf")},
+					"a[1]/plain.conf":    {Data: []byte("A = plain")},
+					"a[1]/conf.d/x.conf": {Data: []byte("A += x")},
+					"a1/conf.d/y.conf":   {Data: []byte("A += y")},
+				}
+				So(LoadFS(cfg, fsys, "a[1]/app.conf"), ShouldBeNil)
+				So(cfg.Get("A"), ShouldResemble, []string{"plain", "x"})
+			})
+		})
+
+		Convey("Include search path", func() {
+			fsys := fstest.MapFS{
+				"etc/app.conf":        {Data: []byte(". common.conf\n. redis.conf\n. logging/*.conf")},
+				"etc/common.conf":     {Data: []byte("COMMON = local")},
+				"lib/common.conf":     {Data: []byte("COMMON = lib")},
+				"lib/redis.conf":      {Data: []byte("REDIS = lib")},
+				"site/redis.conf":     {Data: []byte("REDIS = site")},
+				"site/logging/a.conf": {Data: []byte("LOG = site")},
+				"explicit.conf":       {Data: []byte(". ./redis.conf")},
+			}
+			So(LoadFS(cfg, fsys, "etc/app.conf", IncludePath("lib"), IncludePath("site")), ShouldBeNil)
+			So(cfg.Vars, ShouldResemble, map[string][]string{"COMMON": {"local"}, "REDIS": {"lib"}, "LOG": {"site"}})
+
+			So(LoadFS(cfg, fsys, "etc/app.conf", IncludePath("site", "lib")), ShouldBeNil)
+			So(cfg.Get("REDIS"), ShouldResemble, []string{"site"})
+
+			perr := LoadFS(cfg, fsys, "etc/app.conf").(*ParseError)
+			So(perr.Message, ShouldContainSubstring, "etc/redis.conf")
+
+			So(LoadFS(cfg, fsys, "explicit.conf", IncludePath("lib")), ShouldNotBeNil)
+
+			Convey("Base directory for evaluated strings", func() {
+				So(cfg.Eval(". example.conf"), ShouldNotBeNil)
+				So(cfg.Eval(". example.conf", BaseDir("fixtures")), ShouldBeNil)
+				So(cfg.Eval(". example.conf", IncludePath("fixtures")), ShouldBeNil)
+				So(cfg.Get("PGPASSWORD"), ShouldResemble, []string{"dupa.8"})
+			})
+		})
+
+		Convey("Include cycles", func() {
+			fsys := fstest.MapFS{
+				"a.conf":     {Data: []byte("A = a\n. b.conf")},
+				"b.conf":     {Data: []byte("\n. sub/../c.conf")},
+				"c.conf":     {Data: []byte(". /a.conf")},
+				"deep.conf":  {Data: []byte(". deep1.conf")},
+				"deep1.conf": {Data: []byte(". deep2.conf")},
+				"deep2.conf": {Data: []byte(". deep3.conf")},
+				"deep3.conf": {Data: []byte("DEEP = deep")},
+			}
+
+			perr := LoadFS(cfg, fsys, "a.conf").(*ParseError)
+			So(perr.Kind, ShouldEqual, ErrIncludeCycle)
+			So(perr.Message, ShouldEqual, "Include cycle: a.conf -> b.conf -> c.conf -> a.conf")
+			So(perr.Position, ShouldResemble, Position{"c.conf", 1, 1, 0})
+			So(perr.Includes, ShouldResemble, []Position{{"a.conf", 2, 1, 6}, {"b.conf", 2, 1, 1}})
+
+			perr = cfg.Load("fixtures/self.conf").(*ParseError)
+			So(perr.Kind, ShouldEqual, ErrIncludeCycle)
+			So(perr.Message, ShouldEqual, "Include cycle: fixtures/self.conf -> fixtures/self.conf")
+
+			Convey("Include depth", func() {
+				So(LoadFS(cfg, fsys, "deep.conf"), ShouldBeNil)
+				So(LoadFS(cfg, fsys, "deep.conf", MaxIncludeDepth(3)), ShouldBeNil)
+
+				perr := LoadFS(cfg, fsys, "deep.conf", MaxIncludeDepth(2)).(*ParseError)
+				So(perr.Kind, ShouldEqual, ErrIncludeDepth)
+				So(perr.Message, ShouldEqual, "Includes nested deeper than 2: deep.conf -> deep1.conf -> deep2.conf -> deep3.conf")
+				So(perr.Includes, ShouldHaveLength, 2)
+
+				So(cfg.Eval(". fixtures/self.conf", MaxIncludeDepth(0)).(*ParseError).Kind, ShouldEqual, ErrIncludeDepth)
+			})
+		})
+
 		Convey("Serialize", func() {
 			Convey("Works at all", func() {
 				cfg.Eval("FOO = 1\nTony Halik")
@@ -71,6 +298,15 @@ func TestConfig(t *testing.T) {
 				So(reloaded.Vars, ShouldResemble, cfg.Vars)
 				So(reloaded.Lines, ShouldResemble, cfg.Lines)
 			})
+
+			Convey("Keeps lines that look like statements", func() {
+				cfg.Lines = [][]string{{"export", "FOO"}, {".?", "foo.conf"}, {".", "foo.conf"}, {"A=b"}, {"FOO", "=", "bar"}, {"FOO", "+=bar"}}
+				So(cfg.Serialize(), ShouldEqual, "'export' FOO\n'.?' foo.conf\n'.' foo.conf\n'A=b'\n'FOO' = bar\n'FOO' +=bar")
+				reloaded := NewConfig()
+				So(reloaded.Eval(cfg.Serialize()), ShouldBeNil)
+				So(reloaded.Vars, ShouldBeEmpty)
+				So(reloaded.Lines, ShouldResemble, cfg.Lines)
+			})
 		})
 
 		Convey("Save", func() {
@@ -84,3 +320,12 @@ func TestConfig(t *testing.T) {
 		})
 	})
 }
+
+func BenchmarkLoadReaderContinuations(b *testing.B) {
+	source := "FOO = " + strings.Repeat("word \\\n", 20000) + "end\n"
+	for i := 0; i < b.N; i++ {
+		if err := LoadReader(NewConfig(), "(bench)", strings.NewReader(source)); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
@@ -0,0 +1,167 @@
+package shlike
+
+import "encoding"
+import "errors"
+import "fmt"
+import "reflect"
+import "strconv"
+import "strings"
+import "time"
+
+// Error decoding a variable's value
+type DecodeError struct {
+	Name    string   // Variable name
+	Origins []Origin // Where the value comes from, if known (see `ProvenanceConfig`)
+	Err     error
+}
+
+func (e *DecodeError) Error() string {
+	if len(e.Origins) > 0 {
+		return fmt.Sprintf("%s: %s: %v", e.Origins[len(e.Origins)-1], e.Name, e.Err)
+	}
+	return fmt.Sprintf("%s: %v", e.Name, e.Err)
+}
+
+func (e *DecodeError) Unwrap() error {
+	return e.Err
+}
+
+var textUnmarshalerType = reflect.TypeOf((*encoding.TextUnmarshaler)(nil)).Elem()
+var durationType = reflect.TypeOf(time.Duration(0))
+
+// Returns a `*DecodeError` for variable `name` of `c`
+func decodeError(c Config, name string, err error) error {
+	derr := &DecodeError{Name: name, Err: err}
+	if p, ok := c.(interface{ VarOrigin(string) []Origin }); ok {
+		derr.Origins = p.VarOrigin(name)
+	}
+	return derr
+}
+
+// Splits struct tag into variable name and options
+func parseTag(field reflect.StructField) (string, []string) {
+	tag := strings.Split(field.Tag.Get("shlike"), ",")
+	if tag[0] == "" {
+		tag[0] = field.Name
+	}
+	return tag[0], tag[1:]
+}
+
+// Sets fields of struct pointed to by `v` to values of variables of
+// `c`. Variable name is specified by field's `shlike:"NAME"` tag,
+// and defaults to field's name; fields tagged `shlike:"-"` are
+// skipped, and fields of embedded structs are decoded as if they
+// belonged to the outer struct. Fields of unset variables are not
+// changed.
+//
+// Supported field types are strings, booleans, integers (decimal,
+// so that `010` is ten), floating-point numbers, `time.Duration`,
+// types implementing `encoding.TextUnmarshaler`, pointers to these
+// types, and slices of them. Each word of value is a slice element;
+// other types require value to be a single word. Errors are
+// `*DecodeError`.
+func Unmarshal(c Config, v interface{}) error {
+	rv := reflect.ValueOf(v)
+	if rv.Kind() != reflect.Ptr || rv.IsNil() || rv.Elem().Kind() != reflect.Struct {
+		return fmt.Errorf("shlike: Unmarshal needs a non-nil struct pointer, not %T", v)
+	}
+	return unmarshalStruct(c, rv.Elem())
+}
+
+func unmarshalStruct(c Config, rv reflect.Value) error {
+	rt := rv.Type()
+	for i := 0; i < rt.NumField(); i++ {
+		field := rt.Field(i)
+		if field.Anonymous && field.Type.Kind() == reflect.Struct {
+			if err := unmarshalStruct(c, rv.Field(i)); err != nil {
+				return err
+			}
+			continue
+		}
+		if field.PkgPath != "" {
+			// Unexported field
+			continue
+		}
+		name, _ := parseTag(field)
+		if name == "-" {
+			continue
+		}
+		if value := c.Get(name); value != nil {
+			if err := decodeValue(value, rv.Field(i)); err != nil {
+				return decodeError(c, name, err)
+			}
+		}
+	}
+	return nil
+}
+
+// Sets `rv` to `value`
+func decodeValue(value []string, rv reflect.Value) error {
+	if reflect.PtrTo(rv.Type()).Implements(textUnmarshalerType) || rv.Kind() != reflect.Slice {
+		if len(value) != 1 {
+			return fmt.Errorf("expected a single word, got %d", len(value))
+		}
+		return decodeWord(value[0], rv)
+	}
+	slice := reflect.MakeSlice(rv.Type(), len(value), len(value))
+	for i, word := range value {
+		if err := decodeWord(word, slice.Index(i)); err != nil {
+			return err
+		}
+	}
+	rv.Set(slice)
+	return nil
+}
+
+// Sets `rv` to `word`
+func decodeWord(word string, rv reflect.Value) error {
+	if rv.CanAddr() && rv.Addr().Type().Implements(textUnmarshalerType) {
+		return rv.Addr().Interface().(encoding.TextUnmarshaler).UnmarshalText([]byte(word))
+	}
+	if rv.Type() == durationType {
+		d, err := time.ParseDuration(word)
+		if err != nil {
+			return err
+		}
+		rv.SetInt(int64(d))
+		return nil
+	}
+
+	switch rv.Kind() {
+	case reflect.Ptr:
+		elt := reflect.New(rv.Type().Elem())
+		if err := decodeWord(word, elt.Elem()); err != nil {
+			return err
+		}
+		rv.Set(elt)
+	case reflect.String:
+		rv.SetString(word)
+	case reflect.Bool:
+		b, err := strconv.ParseBool(word)
+		if err != nil {
+			return err
+		}
+		rv.SetBool(b)
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		n, err := strconv.ParseInt(word, 10, rv.Type().Bits())
+		if err != nil {
+			return err
+		}
+		rv.SetInt(n)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		n, err := strconv.ParseUint(word, 10, rv.Type().Bits())
+		if err != nil {
+			return err
+		}
+		rv.SetUint(n)
+	case reflect.Float32, reflect.Float64:
+		f, err := strconv.ParseFloat(word, rv.Type().Bits())
+		if err != nil {
+			return err
+		}
+		rv.SetFloat(f)
+	default:
+		return errors.New("unsupported type " + rv.Type().String())
+	}
+	return nil
+}
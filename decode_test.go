@@ -0,0 +1,114 @@
+package shlike
+
+import "errors"
+import "net"
+import "strconv"
+import "testing"
+import "testing/fstest"
+import "time"
+
+import . "github.com/smartystreets/goconvey/convey"
+
+type testDBSettings struct {
+	Host string `shlike:"PGHOST"`
+	Port uint16 `shlike:"PGPORT"`
+}
+
+type testSettings struct {
+	testDBSettings
+	Name     string
+	Hosts    []string      `shlike:"HOSTS"`
+	Ports    []int         `shlike:"PORTS"`
+	Debug    bool          `shlike:"DEBUG"`
+	Timeout  time.Duration `shlike:"TIMEOUT"`
+	Ratio    float64       `shlike:"RATIO"`
+	Addr     net.IP        `shlike:"ADDR"`
+	Retries  *int          `shlike:"RETRIES"`
+	Ignored  string        `shlike:"-"`
+	Default  string        `shlike:"UNSET"`
+	internal string
+}
+
+func TestUnmarshal(t *testing.T) {
+	Convey("Unmarshal", t, func() {
+		cfg := NewConfig()
+		So(cfg.Eval(`
+PGHOST = db.example.com
+PGPORT = 5432
+Name = 'my app'
+HOSTS = a b c
+PORTS = 80 0443
+DEBUG = true
+TIMEOUT = 1m30s
+RATIO = 0.25
+ADDR = 10.0.0.1
+RETRIES = 3
+`), ShouldBeNil)
+
+		v := testSettings{Default: "default", Ignored: "ignored"}
+		So(Unmarshal(cfg, &v), ShouldBeNil)
+		retries := 3
+		So(v, ShouldResemble, testSettings{
+			testDBSettings: testDBSettings{"db.example.com", 5432},
+			Name:           "my app",
+			Hosts:          []string{"a", "b", "c"},
+			Ports:          []int{80, 443},
+			Debug:          true,
+			Timeout:        90 * time.Second,
+			Ratio:          0.25,
+			Addr:           net.ParseIP("10.0.0.1"),
+			Retries:        &retries,
+			Ignored:        "ignored",
+			Default:        "default",
+		})
+
+		Convey("rejects non-struct pointers", func() {
+			So(Unmarshal(cfg, v), ShouldNotBeNil)
+			So(Unmarshal(cfg, (*testSettings)(nil)), ShouldNotBeNil)
+			So(Unmarshal(cfg, &retries), ShouldNotBeNil)
+		})
+	})
+
+	Convey("Unmarshal errors", t, func() {
+		for _, example := range []struct{ source, message string }{
+			{"PGPORT = 5432 5433", "PGPORT: expected a single word, got 2"},
+			{"PGPORT =", "PGPORT: expected a single word, got 0"},
+			{"PGPORT = 100000", `PGPORT: strconv.ParseUint: parsing "100000": value out of range`},
+			{"DEBUG = maybe", `DEBUG: strconv.ParseBool: parsing "maybe": invalid syntax`},
+			{"TIMEOUT = 5", `TIMEOUT: time: missing unit in duration "5"`},
+			{"PORTS = 80 http", `PORTS: strconv.ParseInt: parsing "http": invalid syntax`},
+			{"PORTS = 0x1bb", `PORTS: strconv.ParseInt: parsing "0x1bb": invalid syntax`},
+			{"ADDR = localhost", "ADDR: invalid IP address: localhost"},
+		} {
+			cfg := NewConfig()
+			So(cfg.Eval(example.source), ShouldBeNil)
+			err := Unmarshal(cfg, &testSettings{})
+			So(err, ShouldNotBeNil)
+			So(err.Error(), ShouldEqual, example.message)
+			So(err, ShouldHaveSameTypeAs, &DecodeError{})
+		}
+
+		var numErr *strconv.NumError
+		cfg := NewConfig()
+		cfg.Set("RETRIES", "many")
+		So(errors.As(Unmarshal(cfg, &testSettings{}), &numErr), ShouldBeTrue)
+
+		var unsupported struct {
+			Ch chan int `shlike:"CH"`
+		}
+		cfg.Set("CH", "x")
+		So(Unmarshal(cfg, &unsupported).Error(), ShouldEqual, "CH: unsupported type chan int")
+	})
+
+	Convey("Unmarshal errors include origin", t, func() {
+		cfg := NewProvenanceConfig(nil)
+		fsys := fstest.MapFS{
+			"app.conf": {Data: []byte("PGHOST = localhost\n. db.conf\n")},
+			"db.conf":  {Data: []byte("PGPORT = postgres\n")},
+		}
+		So(LoadFS(cfg, fsys, "app.conf"), ShouldBeNil)
+		err := Unmarshal(cfg, &testSettings{})
+		So(err, ShouldNotBeNil)
+		So(err.Error(), ShouldEqual, `db.conf:1:1 (included from app.conf:2:1): PGPORT: strconv.ParseUint: parsing "postgres": invalid syntax`)
+	})
+}
@@ -0,0 +1,77 @@
+package shlike
+
+import "errors"
+import "fmt"
+
+// Error returned for lines whose first word has no handler
+var ErrUnknownDirective = errors.New("unknown directive")
+
+// A function handling configuration line. `args` are line's words
+// following the directive.
+type Handler func(args []string) error
+
+// Error returned by `Dispatcher.Dispatch` and `ParseLineFlags`
+type DispatchError struct {
+	Origin           // Line's origin, if known (see `ProvenanceConfig`)
+	Line      int    // Index of the line in configuration, from 0 (as in `Config.Line`)
+	Directive string // First word of the line
+	Err       error
+}
+
+func (e *DispatchError) Error() string {
+	if e.Origin.File != "" {
+		return fmt.Sprintf("%s: %s: %v", e.Origin, e.Directive, e.Err)
+	}
+	return fmt.Sprintf("config line #%d: %s: %v", e.Line, e.Directive, e.Err)
+}
+
+func (e *DispatchError) Unwrap() error {
+	return e.Err
+}
+
+// Routes configuration lines to handlers by the line's first word
+// (directive)
+type Dispatcher struct {
+	handlers map[string]Handler
+}
+
+// Returns new dispatcher with no handlers
+func NewDispatcher() *Dispatcher {
+	return &Dispatcher{map[string]Handler{}}
+}
+
+// Registers `handler` for lines starting with `directive`, replacing
+// previously registered one
+func (d *Dispatcher) Register(directive string, handler Handler) {
+	d.handlers[directive] = handler
+}
+
+// Calls handlers for each line of `c`, in order. Stops at first line
+// that has no handler (with `ErrUnknownDirective`) or whose handler
+// returns an error, and returns a `*DispatchError` wrapping the error.
+func (d *Dispatcher) Dispatch(c Config) error {
+	for i := 0; i < c.Length(); i++ {
+		line := c.Line(i)
+		if len(line) == 0 {
+			continue
+		}
+		handler, ok := d.handlers[line[0]]
+		err := ErrUnknownDirective
+		if ok {
+			err = handler(line[1:])
+		}
+		if err != nil {
+			return lineError(c, i, line[0], err)
+		}
+	}
+	return nil
+}
+
+// Returns a `*DispatchError` for line `number` of `c`
+func lineError(c Config, number int, directive string, err error) error {
+	derr := &DispatchError{Line: number, Directive: directive, Err: err}
+	if p, ok := c.(interface{ LineOrigin(int) (Origin, bool) }); ok {
+		derr.Origin, _ = p.LineOrigin(number)
+	}
+	return derr
+}
@@ -0,0 +1,55 @@
+package shlike
+
+import "errors"
+import "testing"
+
+import . "github.com/smartystreets/goconvey/convey"
+
+func TestDispatcher(t *testing.T) {
+	Convey("Dispatcher", t, func() {
+		cfg := NewProvenanceConfig(nil)
+		So(LoadInto(cfg, "fixtures/example.conf"), ShouldBeNil)
+
+		var runs [][]string
+		d := NewDispatcher()
+		d.Register("RUN", func(args []string) error {
+			runs = append(runs, args)
+			return nil
+		})
+		d.Register("run", func(args []string) error { return nil })
+
+		Convey("reports unknown directives", func() {
+			err := d.Dispatch(cfg)
+			So(errors.Is(err, ErrUnknownDirective), ShouldBeTrue)
+			So(err.Error(), ShouldEqual, "fixtures/example.conf:44:1: incomplete: unknown directive")
+			So(err.(*DispatchError).Line, ShouldEqual, 8)
+			So(runs, ShouldHaveLength, 6)
+			So(runs[0], ShouldResemble, []string{"--name=sentry.memcache", "#", "mpasternacki/memcached:latest"})
+		})
+
+		Convey("passes handler errors", func() {
+			failure := errors.New("failure")
+			d.Register("incomplete", func(args []string) error { return nil })
+			d.Register("run", func(args []string) error { return failure })
+			err := d.Dispatch(cfg)
+			So(errors.Is(err, failure), ShouldBeTrue)
+			So(err.Error(), ShouldEqual, "fixtures/example.conf:33:1: run: failure")
+		})
+
+		Convey("dispatches all lines", func() {
+			d.Register("incomplete", func(args []string) error {
+				So(args, ShouldBeEmpty)
+				return nil
+			})
+			So(d.Dispatch(cfg), ShouldBeNil)
+		})
+	})
+
+	Convey("Dispatcher without provenance", t, func() {
+		cfg := NewConfig()
+		So(cfg.Eval("FOO bar"), ShouldBeNil)
+		err := NewDispatcher().Dispatch(cfg)
+		So(err, ShouldNotBeNil)
+		So(err.Error(), ShouldEqual, "config line #0: FOO: unknown directive")
+	})
+}
@@ -0,0 +1,137 @@
+package shlike
+
+import "fmt"
+import "io/ioutil"
+import "os"
+import "strings"
+
+// A configuration file that can be edited while preserving its
+// comments, formatting, includes, and variable references. Only the
+// edited statements are rewritten.
+type Document struct {
+	file *File
+}
+
+// Parses configuration `source` into an editable document. `name` is
+// used in positions.
+func ParseDocument(name, source string) (*Document, error) {
+	file, err := Parse(name, source)
+	if err != nil {
+		return nil, err
+	}
+	return &Document{file}, nil
+}
+
+// Reads configuration file at `path` into an editable document
+func LoadDocument(path string) (*Document, error) {
+	source, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	return ParseDocument(path, string(source))
+}
+
+// Returns document's syntax tree
+func (d *Document) File() *File {
+	return d.file
+}
+
+// Returns document's source
+func (d *Document) String() string {
+	return d.file.Source
+}
+
+// Returns document's source
+func (d *Document) Bytes() []byte {
+	return []byte(d.file.Source)
+}
+
+// Writes document to file at `path`, keeping mode of existing file
+func (d *Document) Save(path string) error {
+	mode := os.FileMode(0644)
+	if fi, err := os.Stat(path); err == nil {
+		mode = fi.Mode().Perm()
+	}
+	return ioutil.WriteFile(path, d.Bytes(), mode)
+}
+
+// Sets variable `name` to `values`. Value of the last plain (`=`)
+// assignment to the variable is replaced; if there is no such
+// assignment, a new one is appended at end of the document. Later
+// appends (`+=`) to the variable are kept.
+func (d *Document) Set(name string, values ...string) error {
+	if !rxName.MatchString(name) {
+		return fmt.Errorf("Invalid variable name %#v", name)
+	}
+	var last *AssignStmt
+	for _, stmt := range d.file.Stmts {
+		if a, ok := stmt.(*AssignStmt); ok && a.Name == name && a.Op == "=" {
+			last = a
+		}
+	}
+	if last == nil {
+		return d.AppendLine(strings.TrimSuffix(name+" = "+EscapeLine(values), " "))
+	}
+	src := d.file.Source
+	head, tail := src[:last.ValuePos.Offset], src[last.End().Offset:]
+	if last.End() == last.ValuePos && strings.HasPrefix(tail, "#") {
+		// White space after empty value separates the comment
+		value := strings.TrimRight(head, " \t\f\v")
+		head, tail = value, head[len(value):]+tail
+	}
+	if len(values) == 0 {
+		// No white space (nor line continuation) after the operator
+		head = strings.TrimRight(head, " \t\f\v\\\r\n")
+	} else if !strings.ContainsAny(head[len(head)-1:], " \t\f\v") {
+		// Previously empty value
+		head += " "
+	}
+	return d.update(head + EscapeLine(values) + tail)
+}
+
+// Appends a raw `line` of configuration source at end of the document
+func (d *Document) AppendLine(line string) error {
+	src := d.file.Source
+	if src != "" && !strings.HasSuffix(src, "\n") {
+		src += "\n"
+	}
+	if d.continued() {
+		// Blank line ends the last statement
+		src += "\n"
+	}
+	return d.update(src + line + "\n")
+}
+
+// Returns true if document's source ends with a line continuation
+func (d *Document) continued() bool {
+	src := strings.TrimSuffix(d.file.Source, "\n")
+	if !strings.HasSuffix(src, "\\") {
+		return false
+	}
+	offset := len(src) - 1
+	if stmts := d.file.Stmts; len(stmts) > 0 && stmts[len(stmts)-1].End().Offset > offset {
+		// Escaped backslash
+		return false
+	}
+	if comments := d.file.Comments; len(comments) > 0 && comments[len(comments)-1].End().Offset > offset {
+		return false
+	}
+	return true
+}
+
+// Appends a line consisting of escaped `words` at end of the
+// document. Leading words are quoted if they would make the line an
+// assignment or a directive.
+func (d *Document) AppendWords(words ...string) error {
+	return d.AppendLine(escapeCommand(words))
+}
+
+// Replaces document's source, keeping the syntax tree in sync
+func (d *Document) update(source string) error {
+	file, err := Parse(d.file.Name, source)
+	if err != nil {
+		return err
+	}
+	d.file = file
+	return nil
+}
@@ -0,0 +1,152 @@
+package shlike
+
+import "io/ioutil"
+import "os"
+import "path/filepath"
+import "testing"
+
+import . "github.com/smartystreets/goconvey/convey"
+
+func TestDocument(t *testing.T) {
+	Convey("Document", t, func() {
+		src := `# Database settings
+. defaults.conf
+export PGHOST = localhost   # overridden in production
+PGPORT = 5432
+PGPORT += \
+    5433
+DSN ?= "postgres://$PGHOST:${PGPORT[0]}"
+run "$DSN"
+`
+		doc, err := ParseDocument("app.conf", src)
+		So(err, ShouldBeNil)
+		So(doc.String(), ShouldEqual, src)
+
+		Convey("Set replaces value of last assignment", func() {
+			So(doc.Set("PGHOST", "db.example.com", "it's"), ShouldBeNil)
+			So(doc.String(), ShouldEqual, `# Database settings
+. defaults.conf
+export PGHOST = db.example.com 'it'\''s'   # overridden in production
+PGPORT = 5432
+PGPORT += \
+    5433
+DSN ?= "postgres://$PGHOST:${PGPORT[0]}"
+run "$DSN"
+`)
+			So(doc.File().Stmts[1].(*AssignStmt).Value, ShouldHaveLength, 2)
+
+			So(doc.Set("PGPORT"), ShouldBeNil)
+			So(doc.String(), ShouldContainSubstring, "\nPGPORT =\nPGPORT += \\\n")
+
+			So(doc.Set("PGHOST"), ShouldBeNil)
+			So(doc.String(), ShouldContainSubstring, "\nexport PGHOST =   # overridden in production\n")
+		})
+
+		Convey("Set replaces empty value", func() {
+			doc, err := ParseDocument("app.conf", "FOO =\nBAR = x\n")
+			So(err, ShouldBeNil)
+			So(doc.Set("FOO", "v w"), ShouldBeNil)
+			So(doc.String(), ShouldEqual, "FOO = 'v w'\nBAR = x\n")
+		})
+
+		Convey("Set keeps white space before comment after empty value", func() {
+			doc, err := ParseDocument("app.conf", "FOO =   # c\n")
+			So(err, ShouldBeNil)
+			So(doc.Set("FOO"), ShouldBeNil)
+			So(doc.String(), ShouldEqual, "FOO =   # c\n")
+			So(doc.Set("FOO", "new val"), ShouldBeNil)
+			So(doc.String(), ShouldEqual, "FOO = 'new val'   # c\n")
+
+			doc, err = ParseDocument("app.conf", "FOO = # c\n")
+			So(err, ShouldBeNil)
+			So(doc.Set("FOO", "new val"), ShouldBeNil)
+			So(doc.String(), ShouldEqual, "FOO = 'new val' # c\n")
+		})
+
+		Convey("Set appends a new assignment", func() {
+			So(doc.Set("DSN", "postgres:///app"), ShouldBeNil)
+			So(doc.String(), ShouldEqual, src+"DSN = postgres:///app\n")
+
+			So(doc.Set("EMPTY"), ShouldBeNil)
+			So(doc.String(), ShouldEndWith, "\nEMPTY =\n")
+
+			So(doc.Set("not a name", "foo"), ShouldNotBeNil)
+		})
+
+		Convey("AppendLine", func() {
+			doc, err := ParseDocument("app.conf", "FOO = foo")
+			So(err, ShouldBeNil)
+			So(doc.AppendLine("run $FOO"), ShouldBeNil)
+			So(doc.AppendWords("echo", "$FOO"), ShouldBeNil)
+			So(doc.String(), ShouldEqual, "FOO = foo\nrun $FOO\necho '$FOO'\n")
+
+			So(doc.AppendLine(`"unclosed`), ShouldNotBeNil)
+			So(doc.String(), ShouldEqual, "FOO = foo\nrun $FOO\necho '$FOO'\n")
+
+			cfg := NewConfig()
+			So(EvalInto(cfg, doc.String()), ShouldBeNil)
+			So(cfg.Line(0), ShouldResemble, []string{"run", "foo"})
+			So(cfg.Line(1), ShouldResemble, []string{"echo", "$FOO"})
+
+			Convey("ends statement continued at end of document", func() {
+				doc, err := ParseDocument("app.conf", "X = 1 \\\n")
+				So(err, ShouldBeNil)
+				So(doc.AppendWords("run", "x"), ShouldBeNil)
+				cfg := NewConfig()
+				So(EvalInto(cfg, doc.String()), ShouldBeNil)
+				So(cfg.Get("X"), ShouldResemble, []string{"1"})
+				So(cfg.Lines, ShouldResemble, [][]string{{"run", "x"}})
+
+				doc, err = ParseDocument("app.conf", "export a\\\n")
+				So(err, ShouldBeNil)
+				So(doc.Set("B", "b"), ShouldBeNil)
+				cfg = NewConfig()
+				So(EvalInto(cfg, doc.String()), ShouldBeNil)
+				So(cfg.Get("B"), ShouldResemble, []string{"b"})
+				So(cfg.Exports, ShouldResemble, map[string]bool{"a": true})
+
+				doc, err = ParseDocument("app.conf", "X = \\\\\n# c \\\n")
+				So(err, ShouldBeNil)
+				So(doc.AppendLine("run"), ShouldBeNil)
+				So(doc.String(), ShouldEqual, "X = \\\\\n# c \\\nrun\n")
+			})
+
+			Convey("quotes first word that would start a statement", func() {
+				So(doc.AppendWords("A=b", "c d"), ShouldBeNil)
+				So(doc.AppendWords(".", "foo"), ShouldBeNil)
+				So(doc.AppendWords("export", "FOO"), ShouldBeNil)
+				So(doc.String(), ShouldEndWith, "\n'A=b' 'c d'\n'.' foo\n'export' FOO\n")
+
+				cfg := NewConfig()
+				So(EvalInto(cfg, doc.String()), ShouldBeNil)
+				So(cfg.Get("A"), ShouldBeNil)
+				So(cfg.Lines[2:], ShouldResemble, [][]string{{"A=b", "c d"}, {".", "foo"}, {"export", "FOO"}})
+			})
+		})
+
+		Convey("Save and LoadDocument", func() {
+			dir, err := ioutil.TempDir("", "shlike")
+			So(err, ShouldBeNil)
+			defer os.RemoveAll(dir)
+			path := filepath.Join(dir, "app.conf")
+
+			So(doc.Set("PGPORT", "6432"), ShouldBeNil)
+			So(doc.Save(path), ShouldBeNil)
+
+			doc, err := LoadDocument(path)
+			So(err, ShouldBeNil)
+			So(doc.Bytes(), ShouldResemble, []byte(`# Database settings
+. defaults.conf
+export PGHOST = localhost   # overridden in production
+PGPORT = 6432
+PGPORT += \
+    5433
+DSN ?= "postgres://$PGHOST:${PGPORT[0]}"
+run "$DSN"
+`))
+
+			_, err = LoadDocument(filepath.Join(dir, "nonexistent.conf"))
+			So(err, ShouldNotBeNil)
+		})
+	})
+}
@@ -0,0 +1,152 @@
+package shlike
+
+import "bytes"
+import "encoding"
+import "errors"
+import "fmt"
+import "reflect"
+import "strconv"
+import "time"
+
+var textMarshalerType = reflect.TypeOf((*encoding.TextMarshaler)(nil)).Elem()
+
+// Returns configuration source assigning fields of struct `v` (or a
+// pointer to struct) to variables, one assignment per line, in order
+// of fields. Field tags and supported types are the same as for
+// `Unmarshal`; slices are written as multiple words. Fields tagged
+// with `omitempty` option (e.g. `shlike:"NAME,omitempty"`) are skipped
+// if they have zero value or are empty slices, and nil pointers are
+// always skipped.
+func Marshal(v interface{}) ([]byte, error) {
+	rv := reflect.ValueOf(v)
+	if rv.Kind() == reflect.Ptr && !rv.IsNil() {
+		rv = rv.Elem()
+	}
+	if rv.Kind() != reflect.Struct {
+		return nil, fmt.Errorf("shlike: Marshal needs a struct or a struct pointer, not %T", v)
+	}
+	if !rv.CanAddr() {
+		// Fields need to be addressable for pointer receiver MarshalText
+		ptr := reflect.New(rv.Type())
+		ptr.Elem().Set(rv)
+		rv = ptr.Elem()
+	}
+	var buf bytes.Buffer
+	if err := marshalStruct(&buf, rv); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+func marshalStruct(buf *bytes.Buffer, rv reflect.Value) error {
+	rt := rv.Type()
+	for i := 0; i < rt.NumField(); i++ {
+		field := rt.Field(i)
+		if field.Anonymous && field.Type.Kind() == reflect.Struct {
+			if err := marshalStruct(buf, rv.Field(i)); err != nil {
+				return err
+			}
+			continue
+		}
+		if field.PkgPath != "" {
+			// Unexported field
+			continue
+		}
+		name, opts := parseTag(field)
+		fv := rv.Field(i)
+		switch {
+		case name == "-":
+			continue
+		case fv.Kind() == reflect.Ptr && fv.IsNil():
+			continue
+		case hasOption(opts, "omitempty") && (fv.IsZero() || fv.Kind() == reflect.Slice && fv.Len() == 0):
+			continue
+		case !rxName.MatchString(name):
+			return fmt.Errorf("shlike: invalid variable name %#v of field %s", name, field.Name)
+		}
+
+		words, err := encodeValue(fv)
+		if err != nil {
+			return fmt.Errorf("shlike: %s: %v", name, err)
+		}
+		buf.WriteString(name + " =")
+		if len(words) > 0 {
+			buf.WriteString(" " + EscapeLine(words))
+		}
+		buf.WriteByte('\n')
+	}
+	return nil
+}
+
+func hasOption(opts []string, opt string) bool {
+	for _, o := range opts {
+		if o == opt {
+			return true
+		}
+	}
+	return false
+}
+
+// Returns words of value `rv`
+func encodeValue(rv reflect.Value) ([]string, error) {
+	if rv.Kind() != reflect.Slice || isTextMarshaler(rv) {
+		word, err := encodeWord(rv)
+		if err != nil {
+			return nil, err
+		}
+		return []string{word}, nil
+	}
+	words := make([]string, rv.Len())
+	for i := range words {
+		word, err := encodeWord(rv.Index(i))
+		if err != nil {
+			return nil, err
+		}
+		words[i] = word
+	}
+	return words, nil
+}
+
+// Returns value `rv` as a single word
+func encodeWord(rv reflect.Value) (string, error) {
+	if rv.Type().Implements(textMarshalerType) {
+		if rv.Kind() == reflect.Ptr && rv.IsNil() {
+			return "", nil
+		}
+		text, err := rv.Interface().(encoding.TextMarshaler).MarshalText()
+		return string(text), err
+	}
+	if rv.CanAddr() && rv.Addr().Type().Implements(textMarshalerType) {
+		text, err := rv.Addr().Interface().(encoding.TextMarshaler).MarshalText()
+		return string(text), err
+	}
+	if rv.Type() == durationType {
+		return time.Duration(rv.Int()).String(), nil
+	}
+
+	switch rv.Kind() {
+	case reflect.Ptr:
+		if rv.IsNil() {
+			return "", nil
+		}
+		return encodeWord(rv.Elem())
+	case reflect.String:
+		return rv.String(), nil
+	case reflect.Bool:
+		return strconv.FormatBool(rv.Bool()), nil
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return strconv.FormatInt(rv.Int(), 10), nil
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return strconv.FormatUint(rv.Uint(), 10), nil
+	case reflect.Float32, reflect.Float64:
+		return strconv.FormatFloat(rv.Float(), 'g', -1, rv.Type().Bits()), nil
+	default:
+		return "", errors.New("unsupported type " + rv.Type().String())
+	}
+}
+
+// Returns true if `rv` or, if it is addressable, its pointer
+// implements `encoding.TextMarshaler`
+func isTextMarshaler(rv reflect.Value) bool {
+	return rv.Type().Implements(textMarshalerType) || rv.CanAddr() && rv.Addr().Type().Implements(textMarshalerType)
+}
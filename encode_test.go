@@ -0,0 +1,101 @@
+package shlike
+
+import "math/big"
+import "net"
+import "testing"
+import "time"
+
+import . "github.com/smartystreets/goconvey/convey"
+
+func TestMarshal(t *testing.T) {
+	Convey("Marshal", t, func() {
+		retries := 3
+		v := testSettings{
+			testDBSettings: testDBSettings{"db.example.com", 5432},
+			Name:           "my app",
+			Hosts:          []string{"a", "b c", "it's"},
+			Ports:          []int{80, 443},
+			Debug:          true,
+			Timeout:        90 * time.Second,
+			Ratio:          0.25,
+			Addr:           net.ParseIP("10.0.0.1"),
+			Retries:        &retries,
+			Ignored:        "ignored",
+		}
+		data, err := Marshal(&v)
+		So(err, ShouldBeNil)
+		So(string(data), ShouldEqual, `PGHOST = db.example.com
+PGPORT = 5432
+Name = 'my app'
+HOSTS = a 'b c' 'it'\''s'
+PORTS = 80 443
+DEBUG = true
+TIMEOUT = 1m30s
+RATIO = 0.25
+ADDR = 10.0.0.1
+RETRIES = 3
+UNSET = ''
+`)
+
+		Convey("round-trips through Unmarshal", func() {
+			cfg := NewConfig()
+			So(cfg.Eval(string(data)), ShouldBeNil)
+			var decoded testSettings
+			So(Unmarshal(cfg, &decoded), ShouldBeNil)
+			v.Ignored = ""
+			So(decoded, ShouldResemble, v)
+		})
+	})
+
+	Convey("Marshal with omitempty", t, func() {
+		data, err := Marshal(struct {
+			A string   `shlike:"A,omitempty"`
+			B []string `shlike:"B,omitempty"`
+			C int      `shlike:",omitempty"`
+			D []string `shlike:"D"`
+			E *int     `shlike:"E"`
+			F int      `shlike:"F,omitempty"`
+		}{D: []string{}, F: 1})
+		So(err, ShouldBeNil)
+		So(string(data), ShouldEqual, "D =\nF = 1\n")
+	})
+
+	Convey("Marshal with pointer receiver MarshalText", t, func() {
+		type bigSettings struct {
+			N  big.Int   `shlike:"N"`
+			NS []big.Int `shlike:"NS"`
+		}
+		var v bigSettings
+		v.N.SetString("123456789012345678901234567890", 10)
+		v.NS = []big.Int{*big.NewInt(1), *big.NewInt(-2)}
+
+		data, err := Marshal(&v)
+		So(err, ShouldBeNil)
+		So(string(data), ShouldEqual, "N = 123456789012345678901234567890\nNS = 1 -2\n")
+
+		byValue, err := Marshal(v)
+		So(err, ShouldBeNil)
+		So(string(byValue), ShouldEqual, string(data))
+
+		cfg := NewConfig()
+		So(cfg.Eval(string(data)), ShouldBeNil)
+		var decoded bigSettings
+		So(Unmarshal(cfg, &decoded), ShouldBeNil)
+		So(decoded.N.String(), ShouldEqual, v.N.String())
+		So(decoded.NS, ShouldHaveLength, 2)
+		So(decoded.NS[1].String(), ShouldEqual, "-2")
+	})
+
+	Convey("Marshal errors", t, func() {
+		_, err := Marshal("foo")
+		So(err, ShouldNotBeNil)
+		_, err = Marshal(struct {
+			A string `shlike:"not a name"`
+		}{})
+		So(err, ShouldNotBeNil)
+		_, err = Marshal(struct {
+			Ch chan int
+		}{})
+		So(err.Error(), ShouldEqual, "shlike: Ch: unsupported type chan int")
+	})
+}
@@ -0,0 +1,41 @@
+package shlike
+
+import "os"
+import "sort"
+import "strings"
+
+// Returns exported variables of `c` as a sorted list of `NAME=value`
+// strings, suitable for `exec.Cmd.Env` (append it to `os.Environ()`
+// to extend the process environment). Words of each value are joined
+// with `glue`. Exported variables that are not set are skipped. If
+// `c` does not implement `Exporter`, all of its variables are
+// returned.
+func Environ(c Config, glue string) []string {
+	var names []string
+	if exp, ok := asExporter(c); ok {
+		names = exp.Exported()
+	} else {
+		names = c.Variables()
+	}
+	sort.Strings(names)
+
+	env := make([]string, 0, len(names))
+	for _, name := range names {
+		if val := c.Get(name); val != nil {
+			env = append(env, name+"="+strings.Join(val, glue))
+		}
+	}
+	return env
+}
+
+// Sets variables returned by `Environ(c, glue)` in the process
+// environment
+func Setenv(c Config, glue string) error {
+	for _, kv := range Environ(c, glue) {
+		splut := strings.SplitN(kv, "=", 2)
+		if err := os.Setenv(splut[0], splut[1]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
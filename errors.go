@@ -0,0 +1,67 @@
+package shlike
+
+import "fmt"
+
+// Kind of a configuration parse error
+type ErrorKind int
+
+const (
+	ErrSyntax                   ErrorKind = iota // Generic syntax error
+	ErrUnclosedDoubleQuote                       // Double quoted string not closed before end of input
+	ErrDotArity                                  // Dot directive with other than one parameter
+	ErrInvalidVariableReference                  // Malformed variable reference
+	ErrInclude                                   // Included file could not be loaded
+	ErrUndefinedVariable                         // Reference to undefined variable in strict mode
+	ErrRequiredVariable                          // Unset variable referenced with `${NAME?message}`
+	ErrIncludeCycle                              // File includes itself, directly or indirectly
+	ErrIncludeDepth                              // Includes are nested too deep
+)
+
+var errorKindNames = map[ErrorKind]string{
+	ErrSyntax:                   "syntax error",
+	ErrUnclosedDoubleQuote:      "unclosed double quote",
+	ErrDotArity:                 "invalid dot arity",
+	ErrInvalidVariableReference: "invalid variable reference",
+	ErrInclude:                  "include failure",
+	ErrUndefinedVariable:        "undefined variable",
+	ErrRequiredVariable:         "required variable",
+	ErrIncludeCycle:             "include cycle",
+	ErrIncludeDepth:             "include depth exceeded",
+}
+
+func (k ErrorKind) String() string {
+	if name, ok := errorKindNames[k]; ok {
+		return name
+	}
+	return fmt.Sprintf("ErrorKind(%d)", int(k))
+}
+
+// A position in configuration source
+type Position struct {
+	File   string // File name, or "(eval)" for evaluated strings
+	Line   int    // Line number, starting at 1
+	Column int    // Column number in bytes, starting at 1
+	Offset int    // Byte offset, starting at 0
+}
+
+func (p Position) String() string {
+	return fmt.Sprintf("%s:%d:%d", p.File, p.Line, p.Column)
+}
+
+// An error encountered while evaluating configuration source
+type ParseError struct {
+	Position
+	Kind     ErrorKind
+	Snippet  string // Offending source fragment
+	Message  string
+	Err      error      // Underlying error, if any
+	Includes []Position // Dot directives through which the file has been included, outermost first
+}
+
+func (e *ParseError) Error() string {
+	return fmt.Sprintf("%s: %s", e.Position, e.Message)
+}
+
+func (e *ParseError) Unwrap() error {
+	return e.Err
+}
@@ -0,0 +1,51 @@
+package shlike
+
+import "flag"
+import "fmt"
+import "io/ioutil"
+import "strings"
+
+// A repeatable flag collecting all its values, e.g. `-e FOO=bar -e
+// BAZ=quux`. Use with `flag.FlagSet.Var`.
+type Strings []string
+
+func (s *Strings) String() string {
+	if s == nil {
+		return ""
+	}
+	return strings.Join(*s, " ")
+}
+
+func (s *Strings) Set(value string) error {
+	*s = append(*s, value)
+	return nil
+}
+
+// Parses `words` (e.g. arguments passed to a `Handler`) as command
+// line flags defined in `fs`, and returns the remaining positional
+// arguments. Parsing stops at the first non-flag word. The flag set
+// is switched to `flag.ContinueOnError` with output discarded, so
+// that errors are only returned.
+func ParseFlags(fs *flag.FlagSet, words []string) ([]string, error) {
+	fs.Init(fs.Name(), flag.ContinueOnError)
+	fs.SetOutput(ioutil.Discard)
+	if err := fs.Parse(words); err != nil {
+		return nil, err
+	}
+	return fs.Args(), nil
+}
+
+// Parses words of line `number` of `c` following the first word
+// (directive) with `ParseFlags`. Flag errors are `*DispatchError`,
+// including line's origin if `c` is a `ProvenanceConfig`.
+func ParseLineFlags(c Config, number int, fs *flag.FlagSet) ([]string, error) {
+	line := c.Line(number)
+	if len(line) == 0 {
+		return nil, fmt.Errorf("config line #%d: no such line", number)
+	}
+	args, err := ParseFlags(fs, line[1:])
+	if err != nil {
+		return nil, lineError(c, number, line[0], err)
+	}
+	return args, nil
+}
@@ -0,0 +1,72 @@
+package shlike
+
+import "errors"
+import "flag"
+import "testing"
+
+import . "github.com/smartystreets/goconvey/convey"
+
+func TestFlags(t *testing.T) {
+	Convey("ParseFlags", t, func() {
+		var env Strings
+		fs := flag.NewFlagSet("RUN", flag.ExitOnError)
+		fs.Var(&env, "e", "environment variable")
+		name := fs.String("name", "", "container name")
+		tty := fs.Bool("t", false, "allocate a TTY")
+
+		args, err := ParseFlags(fs, []string{"-e", "FOO=bar", "-e", "BAZ=quux", "-t", "--name", "redis", "redis:latest", "-x"})
+		So(err, ShouldBeNil)
+		So(args, ShouldResemble, []string{"redis:latest", "-x"})
+		So(env, ShouldResemble, Strings{"FOO=bar", "BAZ=quux"})
+		So(env.String(), ShouldEqual, "FOO=bar BAZ=quux")
+		So(*name, ShouldEqual, "redis")
+		So(*tty, ShouldBeTrue)
+
+		_, err = ParseFlags(fs, []string{"-x"})
+		So(err, ShouldNotBeNil)
+		So(err.Error(), ShouldEqual, "flag provided but not defined: -x")
+	})
+
+	Convey("ParseLineFlags", t, func() {
+		cfg := NewProvenanceConfig(nil)
+		So(LoadInto(cfg, "fixtures/example.conf"), ShouldBeNil)
+
+		newFlagSet := func() (*flag.FlagSet, *Strings) {
+			env := &Strings{}
+			fs := flag.NewFlagSet("RUN", flag.ExitOnError)
+			fs.Var(env, "e", "environment variable")
+			fs.String("name", "", "container name")
+			fs.Bool("t", false, "allocate a TTY")
+			return fs, env
+		}
+
+		fs, env := newFlagSet()
+		args, err := ParseLineFlags(cfg, 4, fs)
+		So(err, ShouldBeNil)
+		So(args, ShouldResemble, []string{"redis:latest", `$whatever`, `what$0ever`})
+		So(*env, ShouldResemble, Strings{"FOO=bar", "BAZ=quux"})
+
+		fs, _ = newFlagSet()
+		_, err = ParseLineFlags(cfg, 5, fs)
+		So(err, ShouldNotBeNil)
+		So(err.Error(), ShouldEqual, "fixtures/example.conf:28:1: RUN: flag provided but not defined: -p")
+
+		_, err = ParseLineFlags(cfg, 100, fs)
+		So(err, ShouldNotBeNil)
+		So(err.Error(), ShouldEqual, "config line #100: no such line")
+
+		Convey("within a dispatcher", func() {
+			d := NewDispatcher()
+			d.Register("RUN", func(words []string) error {
+				fs, _ := newFlagSet()
+				_, err := ParseFlags(fs, words)
+				return err
+			})
+			err := d.Dispatch(cfg)
+			var derr *DispatchError
+			So(errors.As(err, &derr), ShouldBeTrue)
+			So(derr.Origin.Line, ShouldEqual, 20)
+			So(err.Error(), ShouldEqual, "fixtures/example.conf:20:1: RUN: flag provided but not defined: -link")
+		})
+	})
+}
@@ -0,0 +1,150 @@
+package shlike
+
+import "sort"
+import "strings"
+import "unicode/utf8"
+
+// Indentation of continuation lines in formatted source
+const formatIndent = "    "
+
+// Formats configuration `source` in canonical style: statements are
+// unindented, words separated by single spaces, and literal words
+// quoted minimally with `Escape` (a command's leading words keep their
+// quoting if they would start an assignment or a directive otherwise).
+// Line breaks between words of a statement are kept, with the
+// continuation backslashes aligned. Comments and single blank lines
+// are preserved. `name` is used in error messages.
+func Format(name string, source []byte) ([]byte, error) {
+	file, err := Parse(name, string(source))
+	if err != nil {
+		return nil, err
+	}
+
+	nodes := make([]Node, 0, len(file.Stmts)+len(file.Comments))
+	for _, stmt := range file.Stmts {
+		nodes = append(nodes, stmt)
+	}
+	for _, comment := range file.Comments {
+		nodes = append(nodes, comment)
+	}
+	sort.Slice(nodes, func(i, j int) bool { return nodes[i].Pos().Offset < nodes[j].Pos().Offset })
+
+	var lines []string
+	var prev Node
+	for i, node := range nodes {
+		if prev != nil {
+			switch between := file.Source[prev.End().Offset:node.Pos().Offset]; {
+			case !strings.Contains(between, "\n"):
+				// Trailing comment
+				lines[len(lines)-1] += " " + node.(*Comment).Text
+				prev = node
+				continue
+			case strings.Count(between, "\n") > 1:
+				lines = append(lines, "")
+			}
+		}
+		prev = node
+		if comment, ok := node.(*Comment); ok {
+			lines = append(lines, comment.Text)
+		} else {
+			trailing := i+1 < len(nodes) && !strings.Contains(file.Source[node.End().Offset:nodes[i+1].Pos().Offset], "\n")
+			lines = append(lines, formatStmt(file, node.(Stmt), trailing)...)
+		}
+	}
+	if len(lines) == 0 {
+		return []byte{}, nil
+	}
+	return []byte(strings.Join(lines, "\n") + "\n"), nil
+}
+
+// Returns formatted lines of a statement; `trailing` is true if a
+// comment follows it on its last line
+func formatStmt(file *File, stmt Stmt, trailing bool) []string {
+	var head string
+	var words []*Word
+	switch s := stmt.(type) {
+	case *AssignStmt:
+		head = s.Name + " " + s.Op
+		if s.Export {
+			head = "export " + head
+		}
+		words = s.Value
+	case *IncludeStmt:
+		head = "."
+		if s.Optional {
+			head = ".?"
+		}
+		words = s.Words
+	case *ExportStmt:
+		head = "export"
+		words = s.Names
+	case *CommandStmt:
+		words = s.Words
+	}
+
+	texts := make([]string, len(words))
+	for i, word := range words {
+		texts[i] = formatWord(file, word)
+	}
+	if _, ok := stmt.(*CommandStmt); ok {
+		// Leading words keep their source quoting for as long as the
+		// command's first line, as written with the continuation
+		// backslash or trailing comment that follows it, would
+		// otherwise start a statement
+		var suffix string
+		switch {
+		case words[len(words)-1].Pos().Line != stmt.Pos().Line:
+			suffix = " \\"
+		case trailing:
+			suffix = " #"
+		}
+		firstLine := func() string {
+			var first []string
+			for i, word := range words {
+				if word.Pos().Line == stmt.Pos().Line {
+					first = append(first, texts[i])
+				}
+			}
+			return strings.Join(first, " ") + suffix
+		}
+		for i := 0; i < len(words) && rxBOL.FindString(firstLine()) != ""; i++ {
+			texts[i] = file.Text(words[i])
+		}
+	}
+
+	// Words stay on the lines where they started
+	lines := []string{head}
+	line := stmt.Pos().Line
+	for i, word := range words {
+		text := texts[i]
+		if word.Pos().Line != line {
+			line = word.Pos().Line
+			lines = append(lines, formatIndent)
+		}
+		if last := &lines[len(lines)-1]; strings.TrimSpace(*last) == "" {
+			*last += text
+		} else {
+			*last += " " + text
+		}
+	}
+
+	width := 0
+	for _, text := range lines[:len(lines)-1] {
+		if n := utf8.RuneCountInString(text); n > width {
+			width = n
+		}
+	}
+	for i, text := range lines[:len(lines)-1] {
+		lines[i] = text + strings.Repeat(" ", width-utf8.RuneCountInString(text)) + " \\"
+	}
+	return lines
+}
+
+// Returns `word` quoted minimally, or its source text if it contains
+// variable references
+func formatWord(file *File, word *Word) string {
+	if val, ok := word.Literal(); ok {
+		return Escape(val)
+	}
+	return file.Text(word)
+}
@@ -0,0 +1,99 @@
+package shlike
+
+import "testing"
+
+import . "github.com/smartystreets/goconvey/convey"
+
+func TestFormat(t *testing.T) {
+	Convey("Format", t, func() {
+		src := `
+
+# Header comment
+   META=foo   "bar"   'baz'     # trailing comment
+export   PGHOST   ?=db.example.com
+NUMBERS += \
+    16 \
+    23   42 \
+        108
+
+
+
+  . "conf.d/*.conf"
+.?    local.conf
+export A    B
+run  "$META"  x${NUMBERS[0]}y 'it'"'"'s'  "" \
+
+    # indented comment
+`
+		out, err := Format("test.conf", []byte(src))
+		So(err, ShouldBeNil)
+		So(string(out), ShouldEqual, `# Header comment
+META = foo bar baz # trailing comment
+export PGHOST ?= db.example.com
+NUMBERS += \
+    16     \
+    23 42  \
+    108
+
+. conf.d/*.conf
+.? local.conf
+export A B
+run "$META" x${NUMBERS[0]}y 'it'\''s' ''
+
+# indented comment
+`)
+
+		Convey("is idempotent", func() {
+			again, err := Format("test.conf", out)
+			So(err, ShouldBeNil)
+			So(string(again), ShouldEqual, string(out))
+		})
+
+		Convey("preserves meaning", func() {
+			before, after := NewConfig(), NewConfig()
+			opts := []Option{IncludePath("/nonexistent"), CollectWarnings(&[]Warning{})}
+			src := "FOO = foo\\ bar 'baz'\"$X\"\nrun $FOO \\\n  \"${FOO|,}\" 'a  b'\n"
+			src += "'A=b' c\n'.' foo\n\".?\" foo\n'export' FOO\n"
+			src += "FOO '=' bar\nFOO '+=' bar\nFOO \\= bar\nFOO \\\n  = bar\n\"ҩ\" '='0\n"
+			out, err := Format("test.conf", []byte(src))
+			So(err, ShouldBeNil)
+			again, err := Format("test.conf", out)
+			So(err, ShouldBeNil)
+			So(string(again), ShouldEqual, string(out))
+			So(EvalInto(before, src, opts...), ShouldBeNil)
+			So(EvalInto(after, string(out), opts...), ShouldBeNil)
+			So(after, ShouldResemble, before)
+		})
+
+		Convey("keeps quoting before continuations and trailing comments", func() {
+			opts := []Option{IncludePath("/nonexistent"), CollectWarnings(&[]Warning{})}
+			for _, src := range []string{
+				"\"export\" \\\n  x\n",
+				"'.' \\\n  foo\n",
+				"\".?\" \\\n  foo\n",
+				"'export' x # comment\n",
+				"'.?' foo # comment\n",
+				"\"export\" # comment\n",
+			} {
+				before, after := NewConfig(), NewConfig()
+				out, err := Format("test.conf", []byte(src))
+				So(err, ShouldBeNil)
+				So(EvalInto(before, src, opts...), ShouldBeNil)
+				So(EvalInto(after, string(out), opts...), ShouldBeNil)
+				So(after, ShouldResemble, before)
+			}
+		})
+	})
+
+	Convey("Format of empty source", t, func() {
+		out, err := Format("test.conf", []byte("\n\n  \n"))
+		So(err, ShouldBeNil)
+		So(out, ShouldBeEmpty)
+	})
+
+	Convey("Format reports syntax errors", t, func() {
+		_, err := Format("test.conf", []byte(`FOO = "unclosed`))
+		So(err, ShouldNotBeNil)
+		So(err.Error(), ShouldStartWith, "test.conf:1:7: ")
+	})
+}
@@ -8,10 +8,29 @@ func Escape(str string) string {
 	if idx := rxText.FindStringIndex(str); idx != nil && idx[1] == len(str) {
 		return str
 	} else {
-		return "'" + strings.Replace(str, "'", "'\\''", -1) + "'"
+		return quote(str)
 	}
 }
 
+// Returns a string containing `words` as a line of escaped words,
+// quoting leading words that would make the line an assignment or a
+// directive
+func escapeCommand(words []string) string {
+	escaped := make([]string, len(words))
+	for i, word := range words {
+		escaped[i] = Escape(word)
+	}
+	for i := 0; i < len(words) && rxBOL.FindString(strings.Join(escaped, " ")) != ""; i++ {
+		escaped[i] = quote(words[i])
+	}
+	return strings.Join(escaped, " ")
+}
+
+// Returns `str` in single quotes
+func quote(str string) string {
+	return "'" + strings.Replace(str, "'", "'\\''", -1) + "'"
+}
+
 // Returns a string containing `strs` as a line of escaped words
 func EscapeLine(strs []string) string {
 	estrs := make([]string, len(strs))
@@ -0,0 +1,191 @@
+package shlike
+
+import "errors"
+import "io"
+import "io/fs"
+import "os"
+import "path"
+import "path/filepath"
+import "sort"
+import "strings"
+
+// Opens file `name` for reading
+func (o *options) open(name string) (io.ReadCloser, error) {
+	if o.fsys != nil {
+		return o.fsys.Open(name)
+	}
+	return os.Open(name)
+}
+
+// Returns true if file `name` exists
+func (o *options) exists(name string) bool {
+	var err error
+	if o.fsys != nil {
+		_, err = fs.Stat(o.fsys, name)
+	} else {
+		_, err = os.Stat(name)
+	}
+	return err == nil
+}
+
+// Returns directory of file `name`
+func (o *options) dir(name string) string {
+	if o.fsys != nil {
+		return path.Dir(name)
+	}
+	return filepath.Dir(name)
+}
+
+// Returns candidate paths of file `name` included from a file in
+// directory `dir`, in order of preference. Relative paths are
+// relative to `dir` and, unless they start with `./` or `../`, to
+// each of the include directories. If `pattern` is true, `name` is a
+// pattern, and the directories are escaped to match literally.
+func (o *options) includePaths(dir, name string, pattern bool) []string {
+	isAbs, join := filepath.IsAbs, filepath.Join
+	if o.fsys != nil {
+		if path.IsAbs(name) {
+			return []string{strings.TrimLeft(path.Clean(name), "/")}
+		}
+		isAbs, join = path.IsAbs, path.Join
+	}
+	if isAbs(name) {
+		return []string{name}
+	}
+
+	prefix := func(dir string) string {
+		if pattern {
+			return escapeGlob(dir)
+		}
+		return dir
+	}
+	paths := []string{join(prefix(dir), name)}
+	if slashed := filepath.ToSlash(name); !strings.HasPrefix(slashed, "./") && !strings.HasPrefix(slashed, "../") {
+		for _, incdir := range o.incdirs {
+			paths = append(paths, join(prefix(incdir), name))
+		}
+	}
+	return paths
+}
+
+// Returns sorted list of files matching `pattern`
+func (o *options) glob(pattern string) ([]string, error) {
+	var matches []string
+	var err error
+	pattern = negateClasses(pattern)
+	if o.fsys != nil {
+		matches, err = fs.Glob(o.fsys, pattern)
+	} else {
+		matches, err = filepath.Glob(pattern)
+	}
+	sort.Strings(matches)
+	return matches, err
+}
+
+// Returns canonical path of file `name`, used to detect include cycles
+func (o *options) fileKey(name string) string {
+	if o.fsys != nil {
+		return path.Clean(name)
+	}
+	if abs, err := filepath.Abs(name); err == nil {
+		return abs
+	}
+	return filepath.Clean(name)
+}
+
+// Loads file (or files matching a pattern) `name` named by dot
+// directive. If `optional` is true, missing files are skipped.
+func (l *lexer) include(name string, optional bool) {
+	dir := l.dir
+	if dir == "" {
+		dir = l.opts.dir(l.name)
+	}
+	pattern := strings.ContainsAny(name, "*?[")
+	paths := l.opts.includePaths(dir, name, pattern)
+	name = paths[0]
+
+	if !pattern {
+		for _, candidate := range paths {
+			if l.opts.exists(candidate) {
+				name = candidate
+				break
+			}
+		}
+		l.includeFile(name, optional)
+		return
+	}
+
+	var matches []string
+	var err error
+	for _, candidate := range paths {
+		if matches, err = l.opts.glob(candidate); err != nil || len(matches) > 0 {
+			break
+		}
+	}
+	switch {
+	case err != nil:
+		l.errAt(ErrInclude, l.stmt, l.stmt, err, "Invalid include pattern %#v: %v", name, err)
+		l.includeFailed()
+		return
+	case len(matches) == 0 && !optional:
+		l.errAt(ErrInclude, l.stmt, l.stmt, fs.ErrNotExist, "No files match %#v", name)
+		l.includeFailed()
+		return
+	}
+	for _, match := range matches {
+		if l.includeFile(match, optional); l.err != nil {
+			return
+		}
+	}
+}
+
+// Loads a single included file
+func (l *lexer) includeFile(name string, optional bool) {
+	chain := []string{name}
+	depth := 0
+	key := l.opts.fileKey(name)
+	cycle := false
+	for ll := l; ll != nil; ll = ll.parent {
+		chain = append(chain, ll.name)
+		depth++
+		cycle = cycle || ll.key == key
+	}
+	for i, j := 0, len(chain)-1; i < j; i, j = i+1, j-1 {
+		chain[i], chain[j] = chain[j], chain[i]
+	}
+
+	switch {
+	case cycle:
+		l.errAt(ErrIncludeCycle, l.stmt, l.stmt, nil, "Include cycle: %s", strings.Join(chain, " -> "))
+		l.includeFailed()
+		return
+	case depth > l.opts.depth:
+		l.errAt(ErrIncludeDepth, l.stmt, l.stmt, nil, "Includes nested deeper than %d: %s", l.opts.depth, strings.Join(chain, " -> "))
+		l.includeFailed()
+		return
+	}
+
+	if err := loadInto(l.Config, name, l.opts, l); err != nil {
+		if perr, ok := err.(*ParseError); ok {
+			// Error inside included file carries its own position
+			perr.Includes = append([]Position{l.position(l.stmt)}, perr.Includes...)
+			l.err = perr
+		} else if optional && errors.Is(err, fs.ErrNotExist) {
+			// Optional file is missing
+		} else {
+			l.errAt(ErrInclude, l.stmt, l.stmt, err, "Cannot include %#v: %v", name, err)
+			l.includeFailed()
+		}
+	}
+}
+
+// Passes include error to the include error handler, if there is one,
+// and continues evaluation
+func (l *lexer) includeFailed() {
+	if l.opts.includeError != nil {
+		perr := l.err.(*ParseError)
+		perr.Includes = l.includes()
+		l.opts.includeError(perr)
+		l.err = nil
+	}
+}
@@ -1,9 +1,11 @@
 package shlike
 
+import "bufio"
 import "fmt"
+import "io"
 import "os"
-import "path/filepath"
 import "regexp"
+import "strconv"
 import "strings"
 import "unicode/utf8"
 
@@ -18,6 +20,7 @@ const (
 	opAppend
 	opSetIfUnset
 	opDot
+	opExport
 )
 
 const eof = -1
@@ -27,15 +30,97 @@ type lexer struct {
 	name                  string
 	data                  string
 	start, pos, width, ln int
+	mark, stmt, dquoStart int // Offsets of current token, statement, and opening double quote
 	welt, line            []string
 	dquo                  bool
+	nested                bool // Evaluating a word within variable reference
+	pattern               bool // Evaluating a shell pattern within variable reference
 	err                   error
 	target                string
 	op                    opKind
+	export                bool // Export assignment's target
+	optional              bool // Skip missing files in dot directive
+	opts                  *options
+	src                   *bufio.Reader // Remaining input, nil if all input is in `data`
+	buf                   []byte        // Scratch buffer for reading `src`
+	base, baseLine        int           // Bytes and lines discarded from beginning of input
+	parent                *lexer        // Lexer of the including file
+	key                   string        // Canonical path of file being read, for cycle detection
+	dir                   string        // Directory for relative includes, if different than name's
+	tree                  *syntaxTree   // Syntax tree being built instead of evaluating input
 }
 
-func newLexer(c Config, name, data string) *lexer {
-	return &lexer{Config: c, name: name, data: data}
+func newLexer(c Config, name, data string, opts *options) *lexer {
+	return &lexer{Config: c, name: name, data: data, opts: opts}
+}
+
+// Returns a lexer reading its input from `r` incrementally, whole lines at a time
+func newReaderLexer(c Config, name string, r io.Reader, opts *options) *lexer {
+	return &lexer{Config: c, name: name, src: bufio.NewReader(r), opts: opts}
+}
+
+// Minimum number of bytes read into `data` at a time
+const minFill = 4096
+
+// Reads more input into `data`, whole lines at a time. Reads at least
+// as many bytes as are already buffered, so that the buffer doubles
+// and a long statement is not copied once per line. Returns false if
+// there is no more input.
+func (l *lexer) fill() bool {
+	if l.src == nil {
+		return false
+	}
+	want := len(l.data)
+	if want < minFill {
+		want = minFill
+	}
+	buf := l.buf[:0]
+	for len(buf) < want {
+		line, err := l.src.ReadSlice('\n')
+		buf = append(buf, line...)
+		for err == bufio.ErrBufferFull {
+			// Line is longer than reader's buffer
+			line, err = l.src.ReadSlice('\n')
+			buf = append(buf, line...)
+		}
+		if err != nil {
+			l.src = nil
+			if err != io.EOF {
+				l.err = err
+				return false
+			}
+			break
+		}
+	}
+	l.buf = buf
+	l.data += string(buf)
+	return len(buf) > 0
+}
+
+// Reads at least `n` more bytes of input (or the rest of it) into
+// `data`. Returns false if there is no more input.
+// Lexers that rescan buffered input after each read grow it
+// geometrically, so that unterminated tokens are not scanned once per
+// line.
+func (l *lexer) grow(n int) bool {
+	size := len(l.data)
+	for l.fill() && len(l.data)-size < n {
+	}
+	return len(l.data) > size
+}
+
+// Discards already processed input up to `start`. Must be called only
+// at beginning of a line, so that column numbers stay valid.
+func (l *lexer) compact() {
+	if l.start == 0 {
+		return
+	}
+	l.base += l.start
+	l.baseLine += strings.Count(l.data[:l.start], "\n")
+	l.data = l.data[l.start:]
+	l.pos -= l.start
+	l.start = 0
+	l.mark = 0
 }
 
 func (l *lexer) parse() error {
@@ -46,12 +131,34 @@ func (l *lexer) parse() error {
 }
 
 func (l *lexer) lineNumber() int {
-	return 1 + strings.Count(l.data[:l.start], "\n")
+	return l.position(l.start).Line
+}
+
+// Returns position of byte `offset` in lexer's buffered input
+func (l *lexer) position(offset int) Position {
+	return Position{
+		File:   l.name,
+		Line:   l.baseLine + 1 + strings.Count(l.data[:offset], "\n"),
+		Column: offset - strings.LastIndex(l.data[:offset], "\n"),
+		Offset: l.base + offset,
+	}
+}
+
+// Returns source fragment between `start` and `end`, or the rest of
+// line if the region is empty, truncated at the first line break.
+func (l *lexer) snippet(start, end int) string {
+	if end <= start || end > len(l.data) {
+		end = len(l.data)
+	}
+	snip := l.data[start:end]
+	if i := strings.IndexAny(snip, "\r\n"); i >= 0 {
+		snip = snip[:i]
+	}
+	return snip
 }
 
 func (l *lexer) debugPrefix(start, pos int) string {
-	ln := 1 + strings.Count(l.data[:pos], "\n")
-	lpos := pos - strings.LastIndex(l.data[:pos], "\n")
+	p := l.position(pos)
 	before := start - 3
 	after := pos + 3
 	if before < 0 {
@@ -60,10 +167,14 @@ func (l *lexer) debugPrefix(start, pos int) string {
 	if after > len(l.data) {
 		after = len(l.data)
 	}
-	return fmt.Sprintf("%s:%d:%d:\t%#v.%#v.%#v", l.name, ln, lpos, l.data[before:start], l.data[start:pos], l.data[pos:after])
+	return fmt.Sprintf("%s:\t%#v.%#v.%#v", p, l.data[before:start], l.data[start:pos], l.data[pos:after])
 }
 
 func (l *lexer) debug(format string, v ...interface{}) {
+	if l.opts.logger != nil {
+		l.opts.logger.Debug(fmt.Sprintf(format, v...), "at", l.debugPrefix(l.start, l.pos))
+		return
+	}
 	fmt.Fprintf(os.Stderr, "%s\t%s\n", l.debugPrefix(l.start, l.pos), fmt.Sprintf(format, v...))
 }
 
@@ -74,81 +185,311 @@ func (l *lexer) addText(text string) {
 	l.welt = append(l.welt, text)
 }
 
+// Adds quoted text to current word. When evaluating a shell
+// pattern, quoted text is escaped to match literally.
+func (l *lexer) addQuoted(text string) {
+	if l.pattern {
+		text = escapeGlob(text)
+	}
+	l.addText(text)
+}
+
 func (l *lexer) endWord() {
+	if l.tree != nil {
+		l.endSyntaxWord()
+		return
+	}
 	if len(l.welt) > 0 {
 		l.line = append(l.line, strings.Join(l.welt, ""))
 	}
 	l.welt = nil
 }
 
-func (l *lexer) expandReference(vref string) {
-	var name, glue string
+// Returns value of variable `name`. If `env` is true, or variable is
+// not set and environment fallback is enabled, reads the variable from
+// process environment.
+func (l *lexer) lookup(name string, env bool) []string {
+	if !env {
+		if val := l.Get(name); val != nil || l.opts.env == EnvNone {
+			return val
+		}
+	}
+	value, ok := os.LookupEnv(name)
+	switch {
+	case !ok:
+		return nil
+	case l.opts.env == EnvSplit:
+		return strings.Fields(value)
+	default:
+		return []string{value}
+	}
+}
+
+func (l *lexer) expandReference(ref *reference) {
+	val := l.lookup(ref.name, ref.env)
+	if val != nil && ref.sub != nil {
+		val = ref.sub.apply(val)
+		if val == nil {
+			// Index out of range is unset, but not undefined
+			val = []string{}
+			if ref.op != "" {
+				val = nil
+			}
+		}
+	}
 
-	if splut := strings.SplitN(vref, "|", 2); len(splut) == 1 {
-		name = vref
-		glue = " "
-	} else {
-		name = splut[0]
-		glue = splut[1]
+	switch colon := strings.HasPrefix(ref.op, ":"); strings.TrimPrefix(ref.op, ":") {
+	case "-":
+		if isUnset(val, colon) {
+			val = l.expandWord(ref.word[0], ref.word[1])
+		}
+	case "=":
+		if isUnset(val, colon) {
+			val = l.expandWord(ref.word[0], ref.word[1])
+			if l.err == nil {
+				l.track(l.mark)
+				l.Set(ref.name, val...)
+			}
+		}
+	case "?":
+		if isUnset(val, colon) {
+			msg := strings.Join(l.expandWord(ref.word[0], ref.word[1]), " ")
+			if msg == "" {
+				msg = "parameter null or not set"
+			}
+			if l.err == nil {
+				l.errf(ErrRequiredVariable, "%s: %s", ref, msg)
+			}
+			return
+		}
+	case "+":
+		if isUnset(val, colon) {
+			val = []string{}
+		} else {
+			val = l.expandWord(ref.word[0], ref.word[1])
+		}
+	case "#", "##", "%", "%%", "/", "//":
+		if val != nil {
+			val = l.transform(val, ref)
+		}
+	}
+
+	if l.err != nil {
+		return
 	}
-	val := l.Get(name)
 	if val == nil {
-		l.warnf("Undefined variable %#v", vref)
+		if l.opts.strict {
+			l.errf(ErrUndefinedVariable, "Undefined variable %#v", ref.String())
+		} else {
+			l.warnf(WarnUndefinedVariable, "Undefined variable %#v", ref.String())
+		}
 		return
 	}
+	if ref.length {
+		val = []string{strconv.Itoa(len(val))}
+	}
 	if l.dquo {
-		l.welt = append(l.welt, strings.Join(val, glue))
+		l.addQuoted(strings.Join(val, ref.glue))
 	} else {
 		l.endWord()
 		l.line = append(l.line, val...)
 	}
 }
 
+// Applies pattern removal or substitution operator of `ref` to each
+// word of `val`
+func (l *lexer) transform(val []string, ref *reference) []string {
+	pattern := l.expandPattern(ref.word[0], ref.word[1])
+	var repl string
+	if ref.op[0] == '/' {
+		repl = strings.Join(l.expandWord(ref.repl[0], ref.repl[1]), " ")
+	}
+	if l.err != nil {
+		return nil
+	}
+
+	rx, err := compileGlob(pattern)
+	if err != nil {
+		l.errf(ErrInvalidVariableReference, "Invalid pattern %#v: %v", pattern, err)
+		return nil
+	}
+	rv := make([]string, len(val))
+	for i, word := range val {
+		switch ref.op {
+		case "#", "##":
+			rv[i] = trimGlobPrefix(word, rx, ref.op == "##")
+		case "%", "%%":
+			rv[i] = trimGlobSuffix(word, rx, ref.op == "%%")
+		case "/", "//":
+			rv[i] = replaceGlob(word, rx, repl, ref.op == "//")
+		}
+	}
+	return rv
+}
+
+// Evaluates data between offsets `start` and `end` as a shell
+// pattern. Quoted characters are escaped, so that they will match
+// literally; surrounding double quotes do not apply to the pattern.
+func (l *lexer) expandPattern(start, end int) string {
+	return strings.Join(l.subLexer(start, end, true).expand(l), " ")
+}
+
+// Evaluates data between offsets `start` and `end` as a sequence of
+// words, and returns the words. Within double quotes, the data is
+// evaluated as double quoted.
+func (l *lexer) expandWord(start, end int) []string {
+	return l.subLexer(start, end, false).expand(l)
+}
+
+func (l *lexer) subLexer(start, end int, pattern bool) *lexer {
+	return &lexer{
+		Config:   l.Config,
+		name:     l.name,
+		data:     l.data[:end],
+		base:     l.base,
+		baseLine: l.baseLine,
+		start:    start,
+		pos:      start,
+		mark:     start,
+		stmt:     l.stmt,
+		opts:     l.opts,
+		parent:   l.parent,
+		key:      l.key,
+		dquo:     l.dquo && !pattern,
+		nested:   true,
+		pattern:  pattern,
+	}
+}
+
+// Runs nested lexer, and returns words it has read. Errors are
+// passed to `parent`.
+func (l *lexer) expand(parent *lexer) []string {
+	for state := lexFn(lexDispatch); state != nil && l.err == nil; {
+		state = state(l)
+	}
+	if l.err != nil {
+		parent.err = l.err
+		return nil
+	}
+	if l.line == nil {
+		return []string{}
+	}
+	return l.line
+}
+
 func (l *lexer) endLine() {
 	l.endWord()
+	if l.tree != nil {
+		l.endSyntaxLine()
+	} else {
+		l.evalLine()
+	}
+	l.target = ""
+	l.export = false
+	l.optional = false
+	l.op = opLine
+	l.line = nil
+	l.ln = 0
+}
+
+// Applies current statement to the configuration
+func (l *lexer) evalLine() {
 	switch l.op {
 	case opLine:
 		if len(l.line) > 0 {
+			l.track(l.stmt)
 			l.ReceiveLine(l.line)
 		}
 	case opSet:
+		l.track(l.stmt)
 		l.Set(l.target, l.line...)
 	case opAppend:
+		l.track(l.stmt)
 		l.Append(l.target, l.line...)
 	case opSetIfUnset:
 		if l.Get(l.target) == nil {
+			l.track(l.stmt)
 			l.Set(l.target, l.line...)
 		}
+	case opExport:
+		for _, name := range l.line {
+			if !rxName.MatchString(name) {
+				l.errAt(ErrSyntax, l.stmt, l.stmt, nil, "Invalid variable name %#v", name)
+				break
+			}
+		}
+		if l.err == nil {
+			l.exportVariables(l.line...)
+		}
 	case opDot:
 		if len(l.line) != 1 {
-			l.errf("The dot accepts exactly one word as a parameter, not %d", len(l.line))
+			l.errAt(ErrDotArity, l.stmt, l.stmt, nil, "The dot accepts exactly one word as a parameter, not %d", len(l.line))
 		} else {
-			path := l.line[0]
-			if !filepath.IsAbs(path) {
-				// Relative path is relative to current file
-				path = filepath.Join(filepath.Dir(l.name), path)
-			}
-			l.err = LoadInto(l.Config, path)
+			l.include(l.line[0], l.optional)
 		}
 	default:
 		panic(fmt.Sprintf("Unrecognized op %d (called with %#v)", l.op, l.target))
 	}
-	l.target = ""
-	l.op = opLine
-	l.line = nil
-	l.ln = 0
+	if l.export && l.err == nil {
+		l.exportVariables(l.target)
+	}
+}
+
+// Tells configuration, if it is an `OriginTracker`, that the
+// following change originates at `offset`
+func (l *lexer) track(offset int) {
+	if t, ok := l.Config.(OriginTracker); ok {
+		t.SetOrigin(Origin{l.position(offset), l.includes()})
+	}
+}
+
+// Returns positions of dot directives through which current file has
+// been included, outermost first
+func (l *lexer) includes() []Position {
+	var incs []Position
+	for p := l.parent; p != nil; p = p.parent {
+		incs = append([]Position{p.position(p.stmt)}, incs...)
+	}
+	return incs
+}
+
+func (l *lexer) exportVariables(names ...string) {
+	if exp, ok := asExporter(l.Config); ok {
+		exp.Export(names...)
+	} else {
+		l.warnAt(WarnExportUnsupported, l.stmt, "Configuration does not support exporting variables")
+	}
+}
+
+// Sets lexer's error to a `*ParseError` of `kind`, located at
+// `start`, wrapping `err` (which may be nil)
+func (l *lexer) errAt(kind ErrorKind, start, end int, err error, format string, args ...interface{}) {
+	l.err = &ParseError{
+		Position: l.position(start),
+		Kind:     kind,
+		Snippet:  l.snippet(start, end),
+		Message:  fmt.Sprintf(format, args...),
+		Err:      err,
+	}
+}
+
+// Sets lexer's error to a `*ParseError` of `kind` located at current token
+func (l *lexer) errf(kind ErrorKind, format string, args ...interface{}) {
+	l.errAt(kind, l.mark, l.pos, nil, format, args...)
 }
 
-func (l *lexer) errf(format string, args ...interface{}) {
-	l.err = fmt.Errorf("%s: %s", l.debugPrefix(l.start, l.pos), fmt.Sprintf(format, args...))
+// Emits a warning of `kind` located at `offset`
+func (l *lexer) warnAt(kind WarningKind, offset int, format string, args ...interface{}) {
+	l.opts.warning(Warning{l.position(offset), kind, fmt.Sprintf(format, args...)})
 }
 
-func (l *lexer) warnf(format string, args ...interface{}) {
-	fmt.Fprintf(os.Stderr, "%s: WARNING: %s\n", l.debugPrefix(l.start, l.pos), fmt.Sprintf(format, args...))
+// Emits a warning of `kind` located at current token
+func (l *lexer) warnf(kind WarningKind, format string, args ...interface{}) {
+	l.warnAt(kind, l.mark, format, args...)
 }
 
 func (l *lexer) decodeNextRune() (rune, int) {
-	if l.pos >= len(l.data) {
+	if l.pos >= len(l.data) && !l.fill() {
 		return eof, 0
 	}
 	return utf8.DecodeRuneInString(l.data[l.pos:])
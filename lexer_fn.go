@@ -1,15 +1,23 @@
 package shlike
 
 import "regexp"
+import "strings"
 import "unicode"
 
 type lexFn func(*lexer) lexFn
 
-func lexByRx(name string, rx *regexp.Regexp, inner func(*lexer, string, []int) lexFn) lexFn {
+func lexByRx(name string, kind ErrorKind, rx *regexp.Regexp, inner func(*lexer, string, []int) lexFn) lexFn {
 	return func(l *lexer) lexFn {
 		l.rew()
-		if pos := l.match(rx); pos == nil {
-			l.errf("Invalid %s", name)
+		l.mark = l.start
+		pos := l.match(rx)
+		for (pos == nil || l.pos == len(l.data)) && l.grow(len(l.data)-l.start) {
+			// Match may continue in input that has not been read yet
+			l.rew()
+			pos = l.match(rx)
+		}
+		if pos == nil {
+			l.errf(kind, "Invalid %s", name)
 			return nil
 		} else {
 			return inner(l, l.consume(), pos)
@@ -24,8 +32,10 @@ func lexBackslashByRx(l *lexer, region string, pos []int) lexFn {
 	val := region[pos[0]:pos[1]]
 	if val[len(val)-1] == '\n' {
 		// Escaped newline is discarded
+	} else if l.tree != nil {
+		l.addPart(&Literal{l.span(l.mark, l.pos), val})
 	} else {
-		l.addText(val)
+		l.addQuoted(val)
 	}
 	return lexDispatch
 }
@@ -42,43 +52,95 @@ var lexSingleQuoted lexFn
 var rxSingleQuoted = regexp.MustCompile(`(?s)^'([^']*)'`)
 
 func lexSingleQuotedByRx(l *lexer, region string, pos []int) lexFn {
-	l.addText(region[pos[0]:pos[1]])
+	if l.tree != nil {
+		l.addPart(&SingleQuoted{l.span(l.mark, l.pos), region[pos[0]:pos[1]]})
+		return lexDispatch
+	}
+	l.addQuoted(region[pos[0]:pos[1]])
 	return lexDispatch
 }
 
 func lexDoubleQuote(l *lexer) lexFn {
+	start := l.pos
+	if !l.dquo {
+		l.dquoStart = start
+	}
 	l.next()
 	if !l.dquo && l.peek() == '"' {
 		// Empty double quotes shortcut
 		l.next()
 		l.discard()
-		l.addText("")
+		if l.tree != nil {
+			l.addPart(&DoubleQuoted{Span: l.span(start, l.pos)})
+		} else {
+			l.addText("")
+		}
 		return lexDispatch
 	}
 	// Just toggles inside-double-quotes status
 	l.discard()
+	if l.tree != nil {
+		if l.dquo {
+			l.closeQuote(l.pos)
+		} else {
+			l.openQuote(start)
+		}
+	}
 	l.dquo = !l.dquo
 	return lexDispatch
 }
 
 var lexVariableReference lexFn
-var rxVariableReference = regexp.MustCompile(`^\$([-0-9#!$%&*+,.:;<=>?@^_/|~]|[_\pL][_\pL\pN]*|{((?s).*?)})`)
+var rxVariableReference = regexp.MustCompile(`^\$([-0-9#!$%&*+,.:;<=>?@^_/|~]|[_\pL][_\pL\pN]*|{)`)
 
 func lexVariableReferenceByRx(l *lexer, region string, pos []int) lexFn {
-	if pos[2] < 0 {
-		l.expandReference(region[pos[0]:pos[1]])
-	} else {
-		l.expandReference(region[pos[2]:pos[3]])
+	if region != "${" {
+		if l.tree != nil {
+			l.addPart(&VarRef{Span: l.span(l.mark, l.pos), Name: region[pos[0]:pos[1]]})
+			return lexDispatch
+		}
+		l.expandReference(&reference{name: region[pos[0]:pos[1]], glue: " "})
+		return lexDispatch
+	}
+	ref, end := parseReference(l.data, l.pos, l.dquo)
+	for end < 0 && l.grow(len(l.data)-l.pos) {
+		ref, end = parseReference(l.data, l.pos, l.dquo)
+	}
+	switch {
+	case end < 0:
+		l.errAt(ErrInvalidVariableReference, l.mark, l.mark, nil, "Unclosed variable reference")
+		return nil
+	case ref == nil:
+		if close := scanUnquoted(l.data, end, "}", l.dquo); close >= 0 {
+			end = close
+		}
+		l.errAt(ErrInvalidVariableReference, l.mark, end+1, nil, "Invalid variable reference")
+		return nil
+	}
+	expr := l.data[l.pos:end]
+	l.pos = end + 1
+	l.consume()
+	if l.tree != nil {
+		l.addPart(&VarRef{l.span(l.mark, l.pos), ref.name, true, expr})
+		return lexDispatch
 	}
+	l.expandReference(ref)
 	return lexDispatch
 }
 
-var lexText, lexTextDquo lexFn
+var lexText, lexTextDquo, lexTextNested lexFn
 var rxText = regexp.MustCompile(`^[^\\'"$#[:space:]]+`)
 var rxTextDquo = regexp.MustCompile(`^[^\\"$]+`)
+var rxTextNested = regexp.MustCompile(`^[^\\'"$[:space:]]+`)
 
 func lexTextByRx(l *lexer, region string, _ []int) lexFn {
-	l.addText(region)
+	if l.tree != nil {
+		l.addPart(&Literal{l.span(l.mark, l.pos), region})
+	} else if l.dquo {
+		l.addQuoted(region)
+	} else {
+		l.addText(region)
+	}
 	return lexDispatch
 }
 
@@ -86,27 +148,51 @@ var lexLineBreak, lexComment lexFn
 var rxLineBreak = regexp.MustCompile(`^(\r?\n)+`)
 var rxComment = regexp.MustCompile(`(?s)^#[^\n]*(?:\r?\n)*`)
 
-func lexEOLByRx(l *lexer, _ string, _ []int) lexFn {
+func lexEOLByRx(l *lexer, region string, _ []int) lexFn {
+	if l.tree != nil && region[0] == '#' {
+		l.addComment(l.mark, region)
+	}
+	if l.nested {
+		// Line breaks only separate words within variable reference
+		l.endWord()
+		return lexDispatch
+	}
 	l.endLine()
+	l.compact()
 	return lexBOL
 }
 
 var lexBOL lexFn
-var rxBOL = regexp.MustCompile(`^\s*(?:([_\pL][_\pL\pN]*)[\t\v\f ]*([?+]?)=[\t\v\f ]*|(\.)[\t\v\f ]+)?`)
+var rxName = regexp.MustCompile(`^[_\pL][_\pL\pN]*$`)
+var rxBOL = regexp.MustCompile(`^\s*(?:(?:(export)[\t\v\f ]+)?([_\pL][_\pL\pN]*)[\t\v\f ]*([?+]?)=[\t\v\f ]*|(\.\??)[\t\v\f ]+|(export)[\t\v\f ]+)?`)
 
 func lexBOLByRx(l *lexer, region string, pos []int) lexFn {
-	if pos[0] >= 0 {
+	l.stmt = l.mark + len(region) - len(strings.TrimLeftFunc(region, unicode.IsSpace))
+	if pos[2] >= 0 {
 		// Assignment
-		l.target = region[pos[0]:pos[1]]
+		l.target = region[pos[2]:pos[3]]
+		l.export = pos[0] >= 0
 		l.op = opSet
-		switch region[pos[2]:pos[3]] {
+		switch region[pos[4]:pos[5]] {
 		case "+":
 			l.op = opAppend
 		case "?":
 			l.op = opSetIfUnset
 		}
-	} else if pos[4] >= 0 {
+	} else if pos[6] >= 0 {
 		l.op = opDot
+		l.optional = region[pos[6]:pos[7]] == ".?"
+	} else if pos[8] >= 0 {
+		if r := l.peek(); r == eof || r == '\r' || r == '\n' || r == '#' {
+			// `export` without names is an ordinary line, like bare `export`
+			l.pos = l.mark + pos[8]
+			l.discard()
+		} else {
+			l.op = opExport
+		}
+	}
+	if l.tree != nil {
+		l.tree.headEnd = l.pos
 	}
 	return lexDispatch
 }
@@ -123,7 +209,10 @@ func lexDispatch(l *lexer) lexFn {
 		case '"':
 			return lexDoubleQuote
 		case eof:
-			l.errf("Unclosed double quoted string")
+			if l.nested {
+				return lexEOF
+			}
+			l.errAt(ErrUnclosedDoubleQuote, l.dquoStart, l.pos, nil, "Unclosed double quoted string")
 			return nil
 		default:
 			return lexTextDquo
@@ -136,6 +225,9 @@ func lexDispatch(l *lexer) lexFn {
 		case '\\':
 			return lexBackslash
 		case '#':
+			if l.nested {
+				return lexTextNested
+			}
 			return lexComment
 		case '$':
 			return lexVariableReference
@@ -148,6 +240,8 @@ func lexDispatch(l *lexer) lexFn {
 		default:
 			if unicode.IsSpace(r) {
 				return lexWhiteSpace
+			} else if l.nested {
+				return lexTextNested
 			} else {
 				return lexText
 			}
@@ -157,18 +251,23 @@ func lexDispatch(l *lexer) lexFn {
 
 func lexEOF(l *lexer) lexFn {
 	l.discard()
+	if l.nested {
+		l.endWord()
+		return nil
+	}
 	l.endLine()
 	return nil
 }
 
 func init() {
-	lexBackslash = lexByRx("backslash escape", rxBackslash, lexBackslashByRx)
-	lexWhiteSpace = lexByRx("whitespace", rxWhiteSpace, lexWhiteSpaceByRx)
-	lexSingleQuoted = lexByRx("single quoted string", rxSingleQuoted, lexSingleQuotedByRx)
-	lexVariableReference = lexByRx("variable reference", rxVariableReference, lexVariableReferenceByRx)
-	lexText = lexByRx("bare text", rxText, lexTextByRx)
-	lexTextDquo = lexByRx("double quoted text", rxTextDquo, lexTextByRx)
-	lexLineBreak = lexByRx("line break", rxLineBreak, lexEOLByRx)
-	lexComment = lexByRx("comment", rxComment, lexEOLByRx)
-	lexBOL = lexByRx("new line", rxBOL, lexBOLByRx)
+	lexBackslash = lexByRx("backslash escape", ErrSyntax, rxBackslash, lexBackslashByRx)
+	lexWhiteSpace = lexByRx("whitespace", ErrSyntax, rxWhiteSpace, lexWhiteSpaceByRx)
+	lexSingleQuoted = lexByRx("single quoted string", ErrSyntax, rxSingleQuoted, lexSingleQuotedByRx)
+	lexVariableReference = lexByRx("variable reference", ErrInvalidVariableReference, rxVariableReference, lexVariableReferenceByRx)
+	lexText = lexByRx("bare text", ErrSyntax, rxText, lexTextByRx)
+	lexTextDquo = lexByRx("double quoted text", ErrSyntax, rxTextDquo, lexTextByRx)
+	lexTextNested = lexByRx("bare text", ErrSyntax, rxTextNested, lexTextByRx)
+	lexLineBreak = lexByRx("line break", ErrSyntax, rxLineBreak, lexEOLByRx)
+	lexComment = lexByRx("comment", ErrSyntax, rxComment, lexEOLByRx)
+	lexBOL = lexByRx("new line", ErrSyntax, rxBOL, lexBOLByRx)
 }
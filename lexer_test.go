@@ -1,7 +1,11 @@
 package shlike
 
+import "bytes"
 import "io/ioutil"
+import "log/slog"
 import "os"
+import "regexp"
+import "strings"
 import "testing"
 
 import . "github.com/smartystreets/goconvey/convey"
@@ -86,6 +90,41 @@ QUUX = Quux
 			So(c.Get("QUUX"), ShouldResemble, []string{"Quux"})
 		})
 
+		Convey("Export directive", func() {
+			c.Eval(`
+FOO = foo
+export FOO BAR
+export BAZ += baz
+export = not exported
+export
+export   # no names
+`)
+			So(c.Exported(), ShouldResemble, []string{"BAR", "BAZ", "FOO"})
+			So(c.Get("BAZ"), ShouldResemble, []string{"baz"})
+			So(c.Get("export"), ShouldResemble, []string{"not", "exported"})
+			So(c.Lines, ShouldResemble, [][]string{{"export"}, {"export"}})
+
+			perr := c.Eval(`export FOO 'B A R'`).(*ParseError)
+			So(perr.Kind, ShouldEqual, ErrSyntax)
+			So(perr.Message, ShouldEqual, `Invalid variable name "B A R"`)
+
+			Convey("Unsupported by configuration", func() {
+				var warnings []Warning
+				So(EvalInto(struct{ Config }{c}, "export FOO", CollectWarnings(&warnings)), ShouldBeNil)
+				So(warnings, ShouldHaveLength, 1)
+				So(warnings[0].Kind, ShouldEqual, WarnExportUnsupported)
+			})
+
+			Convey("Wrapped in provenance tracking", func() {
+				var warnings []Warning
+				cfg := NewProvenanceConfig(struct{ Config }{c})
+				So(EvalInto(cfg, "export FOO", CollectWarnings(&warnings)), ShouldBeNil)
+				So(warnings, ShouldHaveLength, 1)
+				So(warnings[0].Kind, ShouldEqual, WarnExportUnsupported)
+				So(Environ(cfg, " "), ShouldContain, "FOO=foo")
+			})
+		})
+
 		Convey("Variable expansion", func() {
 			c.Set("FOO", "Tony", "Halik")
 			c.Eval(`$FOO "$FOO" '$FOO' ${FOO} "${FOO}" '${FOO}' tu${FOO}byłem "tu${FOO}byłem" tam$FOO-też "tam$FOO-też" "${FOO|+}"`)
@@ -104,14 +143,285 @@ QUUX = Quux
 			}})
 		})
 
+		Convey("Parameter expansion", func() {
+			c.Set("FOO", "Tony", "Halik")
+			c.Set("EMPTY")
+			c.Set("DEFAULT", "def")
+
+			Convey("Default value", func() {
+				c.Eval(`${FOO:-x} ${UNSET:-a 'b c'} ${EMPTY:-x} ${EMPTY-x} "${UNSET:-$DEFAULT ${FOO|+}}" ${UNSET-a#b|c}`)
+				So(c.Lines, ShouldResemble, [][]string{{
+					"Tony", "Halik", "a", "b c", "x", "def Tony+Halik", "a#b",
+				}})
+			})
+
+			Convey("Single quotes within double quotes are literal", func() {
+				c.Eval(`"${UNSET:-it's}" "${FOO/o/'}"`)
+				So(c.Lines, ShouldResemble, [][]string{{"it's", "T'ny Halik"}})
+			})
+
+			Convey("Assign default value", func() {
+				c.Eval(`${UNSET:=x y} ${EMPTY=z} ${EMPTY:=z}`)
+				So(c.Lines, ShouldResemble, [][]string{{"x", "y", "z"}})
+				So(c.Get("UNSET"), ShouldResemble, []string{"x", "y"})
+				So(c.Get("EMPTY"), ShouldResemble, []string{"z"})
+			})
+
+			Convey("Alternative value", func() {
+				c.Eval(`"${FOO:+set}" "${UNSET:+set}" "${EMPTY+set}" "${EMPTY:+set}"`)
+				So(c.Lines, ShouldResemble, [][]string{{"set", "", "set", ""}})
+			})
+
+			Convey("Required value", func() {
+				So(c.Eval(`${FOO:?} ${EMPTY?}`), ShouldBeNil)
+
+				err := c.Eval(`foo ${EMPTY:?must be "set"}`)
+				So(err, ShouldHaveSameTypeAs, &ParseError{})
+				perr := err.(*ParseError)
+				So(perr.Kind, ShouldEqual, ErrRequiredVariable)
+				So(perr.Message, ShouldEqual, "EMPTY: must be set")
+				So(perr.Column, ShouldEqual, 5)
+
+				perr = c.Eval(`${UNSET?}`).(*ParseError)
+				So(perr.Message, ShouldEqual, "UNSET: parameter null or not set")
+			})
+
+			Convey("Lazy word evaluation", func() {
+				So(c.Eval(`${FOO:-${UNDEFINED}} ${FOO:=${UNDEFINED}}`, Strict()), ShouldBeNil)
+				So(c.Eval(`${UNSET:-${UNDEFINED}}`, Strict()), ShouldNotBeNil)
+			})
+
+			Convey("Word indexing and slicing", func() {
+				c.Set("ARGS", "a", "b", "c", "d")
+				c.Eval(`${ARGS[0]} ${ARGS[-1]} "${ARGS[1:3]}" "${ARGS[:-2]|,}" "${ARGS[2:]}" "${ARGS[3:1]}" "${ARGS[-9:1]}"`)
+				c.Eval(`"${#ARGS}" ${#ARGS[1:]} ${#EMPTY} ${#ARGS[7]}`)
+				c.Eval(`${ARGS[4]} ${ARGS[4]:-x} ${ARGS[-5]-y} "${ARGS[0]:+z}"`)
+				So(c.Lines, ShouldResemble, [][]string{
+					{"a", "d", "b c", "a,b", "c d", "", "a"},
+					{"4", "3", "0", "0"},
+					{"x", "y", "z"},
+				})
+				So(c.Eval(`${ARGS[0]:=x}`), ShouldNotBeNil)
+				So(c.Eval(`${#ARGS:-x}`), ShouldNotBeNil)
+				So(c.Eval(`${ARGS[x]}`), ShouldNotBeNil)
+				for _, ref := range []string{`${ARGS[99999999999999999999:]}`, `${ARGS[:-99999999999999999999]}`, `${ARGS[99999999999999999999]}`} {
+					perr := c.Eval(ref).(*ParseError)
+					So(perr.Kind, ShouldEqual, ErrInvalidVariableReference)
+				}
+			})
+
+			Convey("Pattern removal", func() {
+				c.Set("PATHS", "/srv/app/config.tar.gz", "/etc/app.conf")
+				c.Set("EXT", ".gz")
+				c.Eval(`${PATHS#*/} ${PATHS##*/} ${PATHS%.*} ${PATHS%%.*} ${PATHS%$EXT} ${PATHS#/[!e]*/}`)
+				So(c.Lines, ShouldResemble, [][]string{{
+					"srv/app/config.tar.gz", "etc/app.conf",
+					"config.tar.gz", "app.conf",
+					"/srv/app/config.tar", "/etc/app",
+					"/srv/app/config", "/etc/app",
+					"/srv/app/config.tar", "/etc/app.conf",
+					"app/config.tar.gz", "/etc/app.conf",
+				}})
+			})
+
+			Convey("Pattern substitution", func() {
+				c.Set("IMAGES", "redis:latest", "app:master")
+				c.Set("HOST", "web-01.example.com")
+				c.Eval(`${IMAGES/:*/:1.2} ${HOST/-/_} ${HOST//./-} "${HOST/.*}" ${HOST/[0-9]/?}`)
+				So(c.Lines, ShouldResemble, [][]string{{
+					"redis:1.2", "app:1.2", "web_01.example.com", "web-01-example-com", "web-01", "web-?1.example.com",
+				}})
+			})
+
+			Convey("Quoted pattern characters match literally", func() {
+				c.Set("GLOB", "a*b", "aXb")
+				c.Set("STAR", "*")
+				c.Eval(`${GLOB/'*'/+} ${GLOB/\*/+} ${GLOB/"$STAR"/+} ${GLOB/$STAR/+}`)
+				So(c.Lines, ShouldResemble, [][]string{{
+					"a+b", "aXb", "a+b", "aXb", "a+b", "aXb", "+", "+",
+				}})
+			})
+
+			Convey("Pattern matching", func() {
+				glob := func(pattern string) *regexp.Regexp {
+					rx, err := compileGlob(pattern)
+					So(err, ShouldBeNil)
+					return rx
+				}
+				So(glob(`[a-c]?[!x]\[*`).MatchString("bzy[foo"), ShouldBeTrue)
+				So(glob(`[]x]`).MatchString("]"), ShouldBeTrue)
+				So(glob(`[^x]`).MatchString("x"), ShouldBeFalse)
+				So(glob(`[ab`).MatchString("[ab"), ShouldBeTrue)
+				So(escapeGlob("a*b?[c]\\"), ShouldEqual, "a\\*b\\?\\[c]\\\\")
+			})
+
+			Convey("Invalid references", func() {
+				perr := c.Eval(`${FOO`).(*ParseError)
+				So(perr.Kind, ShouldEqual, ErrInvalidVariableReference)
+				perr = c.Eval(`foo ${FOO!bar}`).(*ParseError)
+				So(perr.Kind, ShouldEqual, ErrInvalidVariableReference)
+				So(perr.Snippet, ShouldEqual, "${FOO!bar}")
+				So(c.Eval(`${FOO:-"}`), ShouldNotBeNil)
+				So(c.Eval(`${FOO:-'}`), ShouldNotBeNil)
+
+				perr = c.Eval(`x ${`).(*ParseError)
+				So(perr.Kind, ShouldEqual, ErrInvalidVariableReference)
+				So(perr.Message, ShouldEqual, "Unclosed variable reference")
+				perr = c.Eval(`x ${env:`).(*ParseError)
+				So(perr.Kind, ShouldEqual, ErrInvalidVariableReference)
+				So(perr.Message, ShouldEqual, "Unclosed variable reference")
+
+				perr = c.Eval(`x ${FOO/[z-a]/y}`).(*ParseError)
+				So(perr.Kind, ShouldEqual, ErrInvalidVariableReference)
+				So(perr.Message, ShouldStartWith, `Invalid pattern "[z-a]": `)
+				So(perr.Column, ShouldEqual, 3)
+				perr = c.Eval(`${FOO#[z-a]}`).(*ParseError)
+				So(perr.Kind, ShouldEqual, ErrInvalidVariableReference)
+				So(perr.Snippet, ShouldEqual, "${FOO#[z-a]}")
+			})
+		})
+
+		Convey("Process environment", func() {
+			t.Setenv("SHLIKE_TEST_WORDS", "foo  bar")
+			t.Setenv("SHLIKE_TEST_EMPTY", "")
+			c.Set("SHLIKE_TEST_WORDS", "config")
+
+			Convey("Explicit env namespace", func() {
+				c.Eval(`${env:SHLIKE_TEST_WORDS} "${env:SHLIKE_TEST_EMPTY}" ${env:SHLIKE_TEST_UNSET:-x} ${#env:SHLIKE_TEST_WORDS}`)
+				c.Eval(`${env:SHLIKE_TEST_WORDS}`, EnvFallback(EnvSplit))
+				So(c.Lines, ShouldResemble, [][]string{
+					{"foo  bar", "", "x", "1"},
+					{"foo", "bar"},
+				})
+				So(c.Eval(`${env:SHLIKE_TEST_UNSET}`, Strict()).(*ParseError).Message, ShouldEqual, `Undefined variable "env:SHLIKE_TEST_UNSET"`)
+				So(c.Eval(`${env:HOME:=x}`), ShouldNotBeNil)
+			})
+
+			Convey("No fallback by default", func() {
+				So(c.Eval(`$SHLIKE_TEST_EMPTY`, Strict()), ShouldNotBeNil)
+			})
+
+			Convey("Fallback", func() {
+				c.Eval(`$SHLIKE_TEST_WORDS "$SHLIKE_TEST_EMPTY" ${SHLIKE_TEST_UNSET:-x}`, EnvFallback(EnvWord))
+				c.Unset("SHLIKE_TEST_WORDS")
+				c.Eval(`$SHLIKE_TEST_WORDS`, EnvFallback(EnvWord))
+				c.Eval(`$SHLIKE_TEST_WORDS $SHLIKE_TEST_EMPTY`, EnvFallback(EnvSplit))
+				So(c.Lines, ShouldResemble, [][]string{
+					{"config", "", "x"},
+					{"foo  bar"},
+					{"foo", "bar"},
+				})
+			})
+		})
+
 		Convey("Invalid input", func() {
 			So(c.Eval(`'foo`), ShouldNotBeNil)
 			So(c.Eval(`"foo`), ShouldNotBeNil)
 			So(c.Eval("fo\ro"), ShouldNotBeNil)
 		})
 
+		Convey("Parse errors", func() {
+			Convey("Unclosed double quote", func() {
+				err := c.Eval("foo\nbar \"baz\nquux")
+				So(err, ShouldHaveSameTypeAs, &ParseError{})
+				perr := err.(*ParseError)
+				So(perr.Kind, ShouldEqual, ErrUnclosedDoubleQuote)
+				So(perr.Position, ShouldResemble, Position{"(eval)", 2, 5, 8})
+				So(perr.Snippet, ShouldEqual, "\"baz")
+				So(perr.Error(), ShouldStartWith, "(eval):2:5: ")
+			})
+
+			Convey("Invalid syntax", func() {
+				perr := c.Eval("foo 'bar").(*ParseError)
+				So(perr.Kind, ShouldEqual, ErrSyntax)
+				So(perr.Position, ShouldResemble, Position{"(eval)", 1, 5, 4})
+				So(perr.Snippet, ShouldEqual, "'bar")
+			})
+
+			Convey("Dot arity", func() {
+				perr := c.Eval("foo\n  . foo bar").(*ParseError)
+				So(perr.Kind, ShouldEqual, ErrDotArity)
+				So(perr.Position, ShouldResemble, Position{"(eval)", 2, 3, 6})
+				So(perr.Snippet, ShouldEqual, ". foo bar")
+			})
+
+			Convey("Include failure", func() {
+				perr := c.Eval(`. fixtures/nonexistent.conf`).(*ParseError)
+				So(perr.Kind, ShouldEqual, ErrInclude)
+				So(perr.Line, ShouldEqual, 1)
+				So(os.IsNotExist(perr.Unwrap()), ShouldBeTrue)
+			})
+
+			Convey("Error kind names", func() {
+				So(ErrInvalidVariableReference.String(), ShouldEqual, "invalid variable reference")
+				So(ErrorKind(-1).String(), ShouldEqual, "ErrorKind(-1)")
+				So(WarnUndefinedVariable.String(), ShouldEqual, "undefined variable")
+				So(WarningKind(-1).String(), ShouldEqual, "WarningKind(-1)")
+			})
+		})
+
 		Convey("Undefined variable warnings", func() {
 			So(stderrFor(func() { c.Eval("$undef") }), ShouldEndWith, "WARNING: Undefined variable \"undef\"\n")
+
+			Convey("Can be collected", func() {
+				var warnings []Warning
+				So(stderrFor(func() { c.Eval("foo\n  bar ${undef}", CollectWarnings(&warnings)) }), ShouldEqual, "")
+				So(warnings, ShouldResemble, []Warning{
+					{Position{"(eval)", 2, 7, 10}, WarnUndefinedVariable, "Undefined variable \"undef\""},
+				})
+			})
+
+			Convey("Can be passed to a handler", func() {
+				var messages []string
+				c.Eval("$undef $undef2", WithWarningHandler(func(w Warning) { messages = append(messages, w.String()) }))
+				So(messages, ShouldResemble, []string{
+					"(eval):1:1: WARNING: Undefined variable \"undef\"",
+					"(eval):1:8: WARNING: Undefined variable \"undef2\"",
+				})
+			})
+
+			Convey("Can be logged", func() {
+				var buf bytes.Buffer
+				logger := slog.New(slog.NewTextHandler(&buf, &slog.HandlerOptions{Level: slog.LevelDebug}))
+				c.Eval("$undef", WithLogger(logger))
+				So(buf.String(), ShouldContainSubstring, "level=WARN msg=\"Undefined variable \\\"undef\\\"\" file=(eval) line=1 column=1 offset=0")
+
+				buf.Reset()
+				newLexer(c, "", "", newOptions([]Option{WithLogger(logger)})).debug("foo")
+				So(buf.String(), ShouldContainSubstring, "level=DEBUG msg=foo")
+			})
+
+			Convey("Are passed into included files", func() {
+				var warnings []Warning
+				So(c.Eval(". fixtures/undefined.conf", CollectWarnings(&warnings)), ShouldBeNil)
+				So(warnings, ShouldResemble, []Warning{
+					{Position{"fixtures/undefined.conf", 1, 5, 4}, WarnUndefinedVariable, "Undefined variable \"UNDEFINED\""},
+				})
+			})
+		})
+
+		Convey("Strict mode", func() {
+			c.Set("REDIS_PORT", "6379")
+			So(c.Eval("PORT = $REDIS_PORT", Strict()), ShouldBeNil)
+			So(c.Get("PORT"), ShouldResemble, []string{"6379"})
+
+			err := c.Eval("foo\nPORT = \"${REDIS_PROT}\"", Strict())
+			So(err, ShouldHaveSameTypeAs, &ParseError{})
+			perr := err.(*ParseError)
+			So(perr.Kind, ShouldEqual, ErrUndefinedVariable)
+			So(perr.Position, ShouldResemble, Position{"(eval)", 2, 9, 12})
+			So(perr.Snippet, ShouldEqual, "${REDIS_PROT}")
+
+			Convey("Applies to included files", func() {
+				perr := c.Eval(". fixtures/undefined.conf", Strict()).(*ParseError)
+				So(perr.Kind, ShouldEqual, ErrUndefinedVariable)
+				So(perr.File, ShouldEqual, "fixtures/undefined.conf")
+			})
+
+			Convey("Applies to loaded files", func() {
+				So(c.Load("fixtures/undefined.conf", Strict()), ShouldNotBeNil)
+				So(stderrFor(func() { So(c.Load("fixtures/undefined.conf"), ShouldBeNil) }), ShouldContainSubstring, "WARNING")
+			})
 		})
 
 		Convey("Dot-include", func() {
@@ -137,8 +447,17 @@ QUUX = Quux
 			})
 		})
 
+		Convey("Incremental input", func() {
+			l := newReaderLexer(c, "(big)", strings.NewReader(strings.Repeat("FOO += foo\n", 1000)+"$FOO"), newOptions(nil))
+			So(l.parse(), ShouldBeNil)
+			So(c.Get("FOO"), ShouldHaveLength, 1000)
+			So(c.Lines[0], ShouldHaveLength, 1000)
+			So(len(l.data), ShouldBeLessThan, 10)
+			So(l.position(l.pos), ShouldResemble, Position{"(big)", 1001, 5, 11004})
+		})
+
 		Convey("Full coverage", func() {
-			l := newLexer(c, "", "")
+			l := newLexer(c, "", "", newOptions(nil))
 			So(stderrFor(func() { l.debug("foo") }), ShouldContainSubstring, "foo")
 			So(func() { l.op = opKind(-1); l.endLine() }, ShouldPanic)
 		})
@@ -0,0 +1,251 @@
+package shlike
+
+import "fmt"
+import "io/ioutil"
+import "regexp"
+import "sort"
+import "strings"
+
+// Rules checked by `Lint`
+const (
+	RuleUndefined      = "undefined"       // Reference to an undefined variable
+	RuleUnused         = "unused"          // Variable is set, but never referenced nor exported
+	RuleOverridden     = "overridden"      // `?=` assignment has no lasting effect
+	RuleQuoting        = "quoting"         // Quotes can be removed without changing the value
+	RuleMissingInclude = "missing-include" // Dot directive names a missing file
+	RuleIncludeCycle   = "include-cycle"   // File includes itself, or includes are nested too deep
+	RuleWarning        = "warning"         // Other evaluation warning
+)
+
+// A problem found by `Lint`
+type Issue struct {
+	Position
+	Rule    string
+	Message string
+}
+
+func (i Issue) String() string {
+	return fmt.Sprintf("%s: %s (%s)", i.Position, i.Message, i.Rule)
+}
+
+// Checks configuration file at `path`, and files it includes.
+// Variables listed in `used` are read by the application, and are not
+// reported as unused. Returns issues ordered by file and position, or
+// an error if the configuration cannot be read or has a syntax error.
+func Lint(path string, used []string, opts ...Option) ([]Issue, error) {
+	var o *options
+	var files []string
+	var issues []Issue
+	opts = append(opts[:len(opts):len(opts)], func(oo *options) {
+		o = oo
+		oo.loaded = func(name string) { files = append(files, name) }
+		oo.includeError = func(err *ParseError) {
+			rule := RuleMissingInclude
+			if err.Kind == ErrIncludeCycle || err.Kind == ErrIncludeDepth {
+				rule = RuleIncludeCycle
+			}
+			issues = append(issues, Issue{err.Position, rule, err.Message})
+		}
+		oo.warn = append(oo.warn, func(w Warning) {
+			rule := RuleWarning
+			if w.Kind == WarnUndefinedVariable {
+				rule = RuleUndefined
+			}
+			issues = append(issues, Issue{w.Position, rule, w.Message})
+		})
+	})
+
+	cfg := &lintConfig{SimpleConfig: NewConfig()}
+	if err := LoadInto(cfg, path, opts...); err != nil {
+		return nil, err
+	}
+
+	ln := &linter{cfg: cfg, referenced: map[string]bool{}}
+	for _, name := range used {
+		ln.referenced[name] = true
+	}
+	order := map[string]int{}
+	for _, name := range files {
+		if _, seen := order[name]; seen {
+			continue
+		}
+		order[name] = len(order)
+		file, err := o.parseFile(name)
+		if err != nil {
+			return nil, err
+		}
+		ln.check(file)
+	}
+	ln.checkUnused()
+
+	// Files included more than once report the same issues again
+	seen := map[Issue]bool{}
+	rv := []Issue{}
+	for _, issue := range append(issues, ln.issues...) {
+		if !seen[issue] {
+			seen[issue] = true
+			rv = append(rv, issue)
+		}
+	}
+	sort.SliceStable(rv, func(i, j int) bool {
+		a, b := rv[i].Position, rv[j].Position
+		if a.File != b.File {
+			return order[a.File] < order[b.File]
+		}
+		return a.Offset < b.Offset
+	})
+	return rv, nil
+}
+
+// Reads and parses file `name`
+func (o *options) parseFile(name string) (*File, error) {
+	f, err := o.open(name)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	source, err := ioutil.ReadAll(f)
+	if err != nil {
+		return nil, err
+	}
+	return Parse(name, string(source))
+}
+
+// Configuration that records origins of all assignments, in order
+type lintConfig struct {
+	*SimpleConfig
+	origin Origin
+	sets   []lintSet
+}
+
+type lintSet struct {
+	name string
+	pos  Position
+}
+
+func (c *lintConfig) SetOrigin(origin Origin) {
+	c.origin = origin
+}
+
+func (c *lintConfig) Set(name string, values ...string) {
+	c.SimpleConfig.Set(name, values...)
+	c.sets = append(c.sets, lintSet{name, c.origin.Position})
+}
+
+// Checks syntax trees of evaluated files
+type linter struct {
+	cfg        *lintConfig
+	issues     []Issue
+	assigned   []*AssignStmt
+	referenced map[string]bool
+}
+
+// Variable names referenced within braced reference's expression
+var rxLintReference = regexp.MustCompile(`\$\{?#?(?:env:)?([_\pL][_\pL\pN]*)`)
+
+func (ln *linter) issue(node Node, rule, format string, args ...interface{}) {
+	ln.issues = append(ln.issues, Issue{node.Pos(), rule, fmt.Sprintf(format, args...)})
+}
+
+func (ln *linter) check(file *File) {
+	for _, stmt := range file.Stmts {
+		var words []*Word
+		switch s := stmt.(type) {
+		case *AssignStmt:
+			ln.assigned = append(ln.assigned, s)
+			if s.Export {
+				ln.referenced[s.Name] = true
+			}
+			if s.Op == "?=" {
+				ln.checkOverridden(s)
+			}
+			words = s.Value
+		case *IncludeStmt:
+			words = s.Words
+		case *ExportStmt:
+			for _, word := range s.Names {
+				if name, ok := word.Literal(); ok {
+					ln.referenced[name] = true
+				}
+			}
+			words = s.Names
+		case *CommandStmt:
+			words = s.Words
+		}
+		for i, word := range words {
+			ln.checkReferences(word.Parts)
+			_, isCommand := stmt.(*CommandStmt)
+			ln.checkQuoting(file, word, isCommand && i == 0)
+		}
+	}
+}
+
+func (ln *linter) checkReferences(parts []Part) {
+	for _, part := range parts {
+		switch p := part.(type) {
+		case *VarRef:
+			ln.referenced[p.Name] = true
+			for _, m := range rxLintReference.FindAllStringSubmatch(p.Expr, -1) {
+				ln.referenced[m[1]] = true
+			}
+		case *DoubleQuoted:
+			ln.checkReferences(p.Parts)
+		}
+	}
+}
+
+// Reports `?=` assignment that has not been evaluated because
+// variable was already set, or whose value has been replaced later
+func (ln *linter) checkOverridden(stmt *AssignStmt) {
+	sets := ln.cfg.sets
+	for i, set := range sets {
+		if set.pos != stmt.Pos() {
+			continue
+		}
+		for _, later := range sets[i+1:] {
+			if later.name == stmt.Name {
+				ln.issue(stmt, RuleOverridden, "Value of %s is overridden at %s", stmt.Name, later.pos)
+				return
+			}
+		}
+		return
+	}
+	ln.issue(stmt, RuleOverridden, "Assignment has no effect, %s is already set", stmt.Name)
+}
+
+// Reports quoted word that can be written bare. First word of a line
+// needs quoting if the line would otherwise start an assignment or a
+// directive.
+func (ln *linter) checkQuoting(file *File, word *Word, first bool) {
+	val, ok := word.Literal()
+	if !ok || val == "" || Escape(val) != val {
+		return
+	}
+	if first {
+		rest := file.Source[word.End().Offset:]
+		if i := strings.IndexByte(rest, '\n'); i >= 0 {
+			rest = rest[:i]
+		}
+		if rxBOL.FindString(val+rest) != "" {
+			return
+		}
+	}
+	for _, part := range word.Parts {
+		switch part.(type) {
+		case *SingleQuoted, *DoubleQuoted:
+			ln.issue(word, RuleQuoting, "Redundant quoting: %s can be written as %s", file.Text(word), val)
+			return
+		}
+	}
+}
+
+// Reports variables that are never referenced
+func (ln *linter) checkUnused() {
+	reported := map[string]bool{}
+	for _, stmt := range ln.assigned {
+		if !ln.referenced[stmt.Name] && !reported[stmt.Name] {
+			reported[stmt.Name] = true
+			ln.issue(stmt, RuleUnused, "Variable %s is set but never used", stmt.Name)
+		}
+	}
+}
@@ -0,0 +1,66 @@
+package shlike
+
+import "io/ioutil"
+import "os"
+import "path/filepath"
+import "testing"
+
+import . "github.com/smartystreets/goconvey/convey"
+
+func TestLint(t *testing.T) {
+	Convey("Lint", t, func() {
+		dir, err := ioutil.TempDir("", "shlike")
+		So(err, ShouldBeNil)
+		defer os.RemoveAll(dir)
+		write := func(name, source string) string {
+			path := filepath.Join(dir, name)
+			So(ioutil.WriteFile(path, []byte(source), 0644), ShouldBeNil)
+			return path
+		}
+
+		write("db.conf", `PGHOST ?= localhost
+PGPORT ?= 5432
+PGUSER = "app"
+. missing.conf
+. app.conf
+'A=b' x
+"FOO" = bar
+`)
+		app := write("app.conf", `PGPORT = 6432
+PGHOST ?= db.example.com
+export PGUSER
+TIMEOUT = 30
+. db.conf
+PGHOST = db2.example.com
+run 'psql' -h $PGHOST -p ${PGPORT:-$DEFAULT_PORT} "$UNDEFINED" '=x' "a b"
+`)
+		db := filepath.Join(dir, "db.conf")
+
+		issues, err := Lint(app, []string{"TIMEOUT"})
+		So(err, ShouldBeNil)
+		So(issues, ShouldResemble, []Issue{
+			{Position{app, 2, 1, 14}, RuleOverridden, "Value of PGHOST is overridden at " + app + ":6:1"},
+			{Position{app, 7, 5, 105}, RuleQuoting, "Redundant quoting: 'psql' can be written as psql"},
+			{Position{app, 7, 52, 152}, RuleUndefined, `Undefined variable "UNDEFINED"`},
+			{Position{app, 7, 64, 164}, RuleQuoting, "Redundant quoting: '=x' can be written as =x"},
+			{Position{db, 1, 1, 0}, RuleOverridden, "Assignment has no effect, PGHOST is already set"},
+			{Position{db, 2, 1, 20}, RuleOverridden, "Assignment has no effect, PGPORT is already set"},
+			{Position{db, 3, 10, 44}, RuleQuoting, `Redundant quoting: "app" can be written as app`},
+			{Position{db, 4, 1, 50}, RuleMissingInclude, `Cannot include "` + filepath.Join(dir, "missing.conf") + `": open ` + filepath.Join(dir, "missing.conf") + `: no such file or directory`},
+			{Position{db, 5, 1, 65}, RuleIncludeCycle, "Include cycle: " + app + " -> " + db + " -> " + app},
+		})
+
+		Convey("Unused variables", func() {
+			issues, err := Lint(app, nil)
+			So(err, ShouldBeNil)
+			So(issues, ShouldContain, Issue{Position{app, 4, 1, 53}, RuleUnused, "Variable TIMEOUT is set but never used"})
+		})
+
+		Convey("Syntax errors", func() {
+			_, err := Lint(write("bad.conf", `FOO = "unclosed`), nil)
+			So(err, ShouldNotBeNil)
+			_, err = Lint(filepath.Join(dir, "nonexistent.conf"), nil)
+			So(err, ShouldNotBeNil)
+		})
+	})
+}
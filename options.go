@@ -0,0 +1,164 @@
+package shlike
+
+import "fmt"
+import "io/fs"
+import "log/slog"
+import "os"
+
+// Kind of a warning
+type WarningKind int
+
+const (
+	WarnGeneric           WarningKind = iota // Other warning
+	WarnUndefinedVariable                    // Reference to undefined variable
+	WarnExportUnsupported                    // Export in configuration that does not support exporting variables
+)
+
+var warningKindNames = map[WarningKind]string{
+	WarnGeneric:           "warning",
+	WarnUndefinedVariable: "undefined variable",
+	WarnExportUnsupported: "export unsupported",
+}
+
+func (k WarningKind) String() string {
+	if name, ok := warningKindNames[k]; ok {
+		return name
+	}
+	return fmt.Sprintf("WarningKind(%d)", int(k))
+}
+
+// A warning emitted while evaluating configuration
+type Warning struct {
+	Position
+	Kind    WarningKind
+	Message string
+}
+
+func (w Warning) String() string {
+	return fmt.Sprintf("%s: WARNING: %s", w.Position, w.Message)
+}
+
+// A function receiving warnings emitted while evaluating configuration
+type WarningHandler func(Warning)
+
+// An evaluation option, accepted by `EvalInto`, `LoadInto`, and
+// their `SimpleConfig` wrappers
+type Option func(*options)
+
+type options struct {
+	warn    []WarningHandler
+	logger  *slog.Logger
+	strict  bool
+	env     EnvMode
+	fsys    fs.FS // File system to read files from, nil for OS file system
+	depth   int
+	incdirs []string
+	basedir string
+
+	loaded       func(name string)     // Called for each loaded file
+	includeError func(err *ParseError) // Receives include errors instead of stopping evaluation
+}
+
+// Default maximum depth of nested includes
+const DefaultMaxIncludeDepth = 32
+
+// Specifies how values are read from process environment
+type EnvMode int
+
+const (
+	EnvNone  EnvMode = iota // Environment is not consulted (except for `${env:NAME}`)
+	EnvWord                 // Environment variable's value is a single word
+	EnvSplit                // Environment variable's value is split into words at white space
+)
+
+func newOptions(opts []Option) *options {
+	o := &options{depth: DefaultMaxIncludeDepth}
+	for _, opt := range opts {
+		opt(o)
+	}
+	return o
+}
+
+// Passes warnings to `handler`. If no warning handler is configured,
+// warnings are written to standard error.
+func WithWarningHandler(handler WarningHandler) Option {
+	return func(o *options) {
+		o.warn = append(o.warn, handler)
+	}
+}
+
+// Logs warnings to `logger` at warning level, and debug messages at
+// debug level
+func WithLogger(logger *slog.Logger) Option {
+	return func(o *options) {
+		o.logger = logger
+		o.warn = append(o.warn, func(w Warning) {
+			logger.Warn(w.Message,
+				slog.String("file", w.File),
+				slog.Int("line", w.Line),
+				slog.Int("column", w.Column),
+				slog.Int("offset", w.Offset))
+		})
+	}
+}
+
+// Appends warnings to `*warnings`
+func CollectWarnings(warnings *[]Warning) Option {
+	return func(o *options) {
+		o.warn = append(o.warn, func(w Warning) {
+			*warnings = append(*warnings, w)
+		})
+	}
+}
+
+// Makes references to undefined variables an error. By default,
+// undefined variables expand to nothing and emit a warning.
+func Strict() Option {
+	return func(o *options) {
+		o.strict = true
+	}
+}
+
+// Makes references to variables that are not set in configuration
+// fall back to process environment. `mode` also specifies how
+// `${env:NAME}` references are split into words.
+func EnvFallback(mode EnvMode) Option {
+	return func(o *options) {
+		o.env = mode
+	}
+}
+
+// Limits depth of nested includes to `depth`
+func MaxIncludeDepth(depth int) Option {
+	return func(o *options) {
+		o.depth = depth
+	}
+}
+
+// Adds `dirs` to the include search path. Relative dot directives
+// that are not found relative to the including file are looked up in
+// the include search path, in order. Paths starting with `./` or
+// `../` are not searched.
+func IncludePath(dirs ...string) Option {
+	return func(o *options) {
+		o.incdirs = append(o.incdirs, dirs...)
+	}
+}
+
+// Resolves relative dot directives in strings evaluated by `EvalInto`
+// against `dir` rather than working directory of the process
+func BaseDir(dir string) Option {
+	return func(o *options) {
+		o.basedir = dir
+	}
+}
+
+func (o *options) warning(w Warning) {
+	if len(o.warn) == 0 {
+		fmt.Fprintln(os.Stderr, w)
+		return
+	}
+	for _, handler := range o.warn {
+		handler(w)
+	}
+}
@@ -0,0 +1,182 @@
+package shlike
+
+import "regexp"
+import "strings"
+import "unicode/utf8"
+
+// Shell pattern matching (as in `${VAR#pattern}`): `*` matches any
+// string, `?` matches any single character, `[...]` matches a
+// character class (negated with `!` or `^`), and backslash quotes the
+// following character.
+
+const globSpecial = `*?[\`
+
+// Escapes `str` so that it matches literally as a shell pattern
+func escapeGlob(str string) string {
+	if !strings.ContainsAny(str, globSpecial) {
+		return str
+	}
+	var buf strings.Builder
+	for _, r := range str {
+		if strings.ContainsRune(globSpecial, r) {
+			buf.WriteByte('\\')
+		}
+		buf.WriteRune(r)
+	}
+	return buf.String()
+}
+
+// Converts `[!...]` character classes of shell pattern to `[^...]`
+// syntax of `path.Match`
+func negateClasses(pattern string) string {
+	if !strings.Contains(pattern, "[!") {
+		return pattern
+	}
+	buf := []byte(pattern)
+	for i := 0; i < len(buf); i++ {
+		switch buf[i] {
+		case '\\':
+			i++
+		case '[':
+			if i++; i < len(buf) && buf[i] == '!' {
+				buf[i] = '^'
+			}
+			// Skip rest of the class, where `[` is literal
+			for ; i < len(buf) && buf[i] != ']'; i++ {
+				if buf[i] == '\\' {
+					i++
+				}
+			}
+		}
+	}
+	return string(buf)
+}
+
+// Compiles shell pattern into an anchored regular expression. Returns
+// an error if the pattern has an invalid character class (e.g. a
+// reversed range).
+func compileGlob(pattern string) (*regexp.Regexp, error) {
+	var buf strings.Builder
+	buf.WriteString(`^(?s:`)
+	for i := 0; i < len(pattern); {
+		r, w := utf8.DecodeRuneInString(pattern[i:])
+		switch r {
+		case '*':
+			buf.WriteString(`.*`)
+		case '?':
+			buf.WriteString(`.`)
+		case '\\':
+			if i+w < len(pattern) {
+				i += w
+				r, w = utf8.DecodeRuneInString(pattern[i:])
+			}
+			buf.WriteString(regexp.QuoteMeta(string(r)))
+		case '[':
+			if class, n := globClass(pattern[i:]); n > 0 {
+				buf.WriteString(class)
+				w = n
+			} else {
+				buf.WriteString(`\[`)
+			}
+		default:
+			buf.WriteString(regexp.QuoteMeta(string(r)))
+		}
+		i += w
+	}
+	buf.WriteString(`)$`)
+	return regexp.Compile(buf.String())
+}
+
+// Translates character class at the beginning of `pattern` into
+// regular expression syntax. Returns translated class and its length
+// in `pattern`, or zero length if the class is not terminated.
+func globClass(pattern string) (string, int) {
+	var buf strings.Builder
+	buf.WriteByte('[')
+	i := 1
+	if i < len(pattern) && (pattern[i] == '!' || pattern[i] == '^') {
+		buf.WriteByte('^')
+		i++
+	}
+	for first := true; i < len(pattern); first = false {
+		r, w := utf8.DecodeRuneInString(pattern[i:])
+		switch {
+		case r == ']' && !first:
+			buf.WriteByte(']')
+			return buf.String(), i + w
+		case r == '\\' && i+w < len(pattern):
+			i += w
+			r, w = utf8.DecodeRuneInString(pattern[i:])
+			buf.WriteString(regexp.QuoteMeta(string(r)))
+		case r == '-':
+			buf.WriteByte('-')
+		default:
+			buf.WriteString(regexp.QuoteMeta(string(r)))
+		}
+		i += w
+	}
+	return "", 0
+}
+
+// Returns offsets of character boundaries in `str`, including its end
+func runeOffsets(str string) []int {
+	offsets := make([]int, 0, len(str)+1)
+	for i := range str {
+		offsets = append(offsets, i)
+	}
+	return append(offsets, len(str))
+}
+
+// Removes shortest (or longest) prefix of `str` matching `rx`
+func trimGlobPrefix(str string, rx *regexp.Regexp, longest bool) string {
+	offsets := runeOffsets(str)
+	for i := range offsets {
+		if longest {
+			i = len(offsets) - 1 - i
+		}
+		if rx.MatchString(str[:offsets[i]]) {
+			return str[offsets[i]:]
+		}
+	}
+	return str
+}
+
+// Removes shortest (or longest) suffix of `str` matching `rx`
+func trimGlobSuffix(str string, rx *regexp.Regexp, longest bool) string {
+	offsets := runeOffsets(str)
+	for i := range offsets {
+		if !longest {
+			i = len(offsets) - 1 - i
+		}
+		if rx.MatchString(str[offsets[i]:]) {
+			return str[:offsets[i]]
+		}
+	}
+	return str
+}
+
+// Replaces the first (or every) longest non-empty match of `rx` in
+// `str` with `repl`
+func replaceGlob(str string, rx *regexp.Regexp, repl string, all bool) string {
+	var buf strings.Builder
+	offsets := runeOffsets(str)
+	last := 0
+	for i := 0; i < len(offsets)-1; i++ {
+		if offsets[i] < last {
+			continue
+		}
+		for j := len(offsets) - 1; j > i; j-- {
+			if rx.MatchString(str[offsets[i]:offsets[j]]) {
+				buf.WriteString(str[last:offsets[i]])
+				buf.WriteString(repl)
+				last = offsets[j]
+				break
+			}
+		}
+		if last > 0 && !all {
+			break
+		}
+	}
+	buf.WriteString(str[last:])
+	return buf.String()
+}
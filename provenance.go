@@ -0,0 +1,110 @@
+package shlike
+
+import "fmt"
+import "strings"
+
+// Origin of a configuration line or variable value
+type Origin struct {
+	Position
+	Includes []Position // Dot directives through which the file has been included, outermost first
+}
+
+func (o Origin) String() string {
+	if len(o.Includes) == 0 {
+		return o.Position.String()
+	}
+	incs := make([]string, len(o.Includes))
+	for i, inc := range o.Includes {
+		incs[len(incs)-1-i] = inc.String()
+	}
+	return fmt.Sprintf("%s (included from %s)", o.Position, strings.Join(incs, ", "))
+}
+
+// A configuration object that keeps track of where the configuration
+// comes from. The lexer calls `SetOrigin` before each `ReceiveLine`,
+// `Set`, and `Append` call.
+type OriginTracker interface {
+	SetOrigin(origin Origin)
+}
+
+// A `Config` wrapper that records origin of each line and variable
+// value
+type ProvenanceConfig struct {
+	Config                          // Wrapped configuration
+	LineOrigins []Origin            // Origin of each line
+	VarOrigins  map[string][]Origin // Origins of assignment and subsequent appends for each variable
+	origin      Origin
+}
+
+// Returns new provenance-recording config wrapping `c`, or a new
+// `SimpleConfig` if `c` is nil
+func NewProvenanceConfig(c Config) *ProvenanceConfig {
+	if c == nil {
+		c = NewConfig()
+	}
+	return &ProvenanceConfig{Config: c, VarOrigins: map[string][]Origin{}}
+}
+
+// Sets origin of the next received line or variable change. Changes
+// that are not preceded by `SetOrigin` (e.g. made directly from Go
+// code) have zero origin.
+func (c *ProvenanceConfig) SetOrigin(origin Origin) {
+	c.origin = origin
+}
+
+func (c *ProvenanceConfig) takeOrigin() Origin {
+	origin := c.origin
+	c.origin = Origin{}
+	return origin
+}
+
+func (c *ProvenanceConfig) ReceiveLine(words []string) {
+	c.Config.ReceiveLine(words)
+	c.LineOrigins = append(c.LineOrigins, c.takeOrigin())
+}
+
+func (c *ProvenanceConfig) Set(name string, values ...string) {
+	c.Config.Set(name, values...)
+	c.VarOrigins[name] = []Origin{c.takeOrigin()}
+}
+
+func (c *ProvenanceConfig) Append(name string, values ...string) {
+	c.Config.Append(name, values...)
+	c.VarOrigins[name] = append(c.VarOrigins[name], c.takeOrigin())
+}
+
+func (c *ProvenanceConfig) Unset(name string) {
+	c.Config.Unset(name)
+	delete(c.VarOrigins, name)
+}
+
+// Marks variables as exported, if wrapped configuration supports it.
+// The lexer warns about export directives when it does not.
+func (c *ProvenanceConfig) Export(names ...string) {
+	if exp, ok := c.Config.(Exporter); ok {
+		exp.Export(names...)
+	}
+}
+
+// Returns exported variables of wrapped configuration, if it supports
+// exporting
+func (c *ProvenanceConfig) Exported() []string {
+	if exp, ok := c.Config.(Exporter); ok {
+		return exp.Exported()
+	}
+	return nil
+}
+
+// Returns origin of line `number`, and false if there is no such line
+func (c *ProvenanceConfig) LineOrigin(number int) (Origin, bool) {
+	if number < 0 || number >= len(c.LineOrigins) {
+		return Origin{}, false
+	}
+	return c.LineOrigins[number], true
+}
+
+// Returns origins of variable's value: the assignment, followed by
+// subsequent appends. Returns nil for unset variable.
+func (c *ProvenanceConfig) VarOrigin(name string) []Origin {
+	return c.VarOrigins[name]
+}
@@ -0,0 +1,71 @@
+package shlike
+
+import "testing"
+import "testing/fstest"
+
+import . "github.com/smartystreets/goconvey/convey"
+
+func TestProvenance(t *testing.T) {
+	Convey("Provenance tracking", t, func() {
+		cfg := NewProvenanceConfig(nil)
+		fsys := fstest.MapFS{
+			"app.conf": {Data: []byte(`
+FOO = foo
+  . db.conf
+run $FOO \
+    ${BAR:=bar}
+FOO += more
+export QUUX = quux
+`)},
+			"db.conf": {Data: []byte("PGPASSWORD ?= secret\nRUN db")},
+		}
+		So(LoadFS(cfg, fsys, "app.conf"), ShouldBeNil)
+
+		Convey("Lines", func() {
+			So(cfg.LineOrigins, ShouldHaveLength, cfg.Length())
+			origin, ok := cfg.LineOrigin(0)
+			So(ok, ShouldBeTrue)
+			So(origin, ShouldResemble, Origin{Position{"db.conf", 2, 1, 21}, []Position{{"app.conf", 3, 3, 13}}})
+			So(origin.String(), ShouldEqual, "db.conf:2:1 (included from app.conf:3:3)")
+
+			origin, _ = cfg.LineOrigin(1)
+			So(origin, ShouldResemble, Origin{Position: Position{"app.conf", 4, 1, 23}})
+			So(origin.String(), ShouldEqual, "app.conf:4:1")
+
+			_, ok = cfg.LineOrigin(2)
+			So(ok, ShouldBeFalse)
+		})
+
+		Convey("Variables", func() {
+			So(cfg.VarOrigin("FOO"), ShouldResemble, []Origin{
+				{Position: Position{"app.conf", 2, 1, 1}},
+				{Position: Position{"app.conf", 6, 1, 50}},
+			})
+			So(cfg.VarOrigin("PGPASSWORD")[0].Position, ShouldResemble, Position{"db.conf", 1, 1, 0})
+			So(cfg.VarOrigin("BAR")[0].Position, ShouldResemble, Position{"app.conf", 5, 5, 38})
+			So(cfg.VarOrigin("QUUX")[0].Position, ShouldResemble, Position{"app.conf", 7, 1, 62})
+			So(cfg.Exported(), ShouldResemble, []string{"QUUX"})
+
+			cfg.Set("FOO", "direct")
+			So(cfg.VarOrigin("FOO"), ShouldResemble, []Origin{{}})
+			cfg.Unset("FOO")
+			So(cfg.VarOrigin("FOO"), ShouldBeNil)
+		})
+
+		Convey("Assignments in nested expansions of included files", func() {
+			cfg := NewProvenanceConfig(nil)
+			fsys := fstest.MapFS{
+				"a.conf": {Data: []byte(". b.conf")},
+				"b.conf": {Data: []byte("run ${A:-${B:=v}}")},
+			}
+			So(LoadFS(cfg, fsys, "a.conf"), ShouldBeNil)
+			So(cfg.VarOrigin("B")[0].String(), ShouldEqual, "b.conf:1:10 (included from a.conf:1:1)")
+		})
+
+		Convey("Wrapping non-exporting config", func() {
+			cfg := NewProvenanceConfig(struct{ Config }{NewConfig()})
+			cfg.Export("FOO")
+			So(cfg.Exported(), ShouldBeNil)
+		})
+	})
+}
@@ -0,0 +1,226 @@
+package shlike
+
+import "regexp"
+import "strconv"
+import "strings"
+
+// A parsed braced variable reference:
+// `${#env:NAME[<subscript>]<op><word>/<replacement>|<glue>}`
+type reference struct {
+	name   string
+	env    bool       // Read from process environment
+	length bool       // Expand to number of words
+	sub    *subscript // Word index or slice, nil if not given
+	op     string     // Expansion operator, empty for plain reference
+	word   [2]int     // Offsets of operator's word in lexer's data
+	repl   [2]int     // Offsets of replacement for substitution operators
+	glue   string     // Glue string for double-quoted expansion
+}
+
+// A word index (`[i]`) or slice (`[from:to]`). Negative numbers
+// count from the end of the list.
+type subscript struct {
+	slice          bool
+	from, to       int
+	hasFrom, hasTo bool
+}
+
+var rxReferenceName = regexp.MustCompile(`^(?:[0-9]+|[-#!$%&*+,.:;<=>?@^_/~]|[_\pL][_\pL\pN]*)`)
+var rxReferenceLength = regexp.MustCompile(`^#(?:env:)?(?:[0-9]+|[-#!$%&*+,.:;<=>?@^_/~]|[_\pL][_\pL\pN]*)[\[}]`)
+var rxReferenceSubscript = regexp.MustCompile(`^\[(?:(-?[0-9]+)|(-?[0-9]+)?(:)(-?[0-9]+)?)\]`)
+var rxReferenceOp = regexp.MustCompile(`^(?::?[-=?+]|##?|%%?|//?)`)
+
+// Parses braced variable reference starting at `start` (just after
+// the opening brace) in `data`. `dquo` tells whether the reference is
+// within double quotes. Returns parsed reference and offset of the
+// closing brace, or nil and offset at which parsing failed (-1 if
+// closing brace has not been found).
+func parseReference(data string, start int, dquo bool) (*reference, int) {
+	ref := &reference{glue: " "}
+	pos := start
+
+	if rxReferenceLength.MatchString(data[pos:]) {
+		ref.length = true
+		pos++
+	}
+
+	if strings.HasPrefix(data[pos:], "env:") {
+		ref.env = true
+		pos += len("env:")
+	}
+
+	name := rxReferenceName.FindString(data[pos:])
+	if name == "" {
+		if pos >= len(data) {
+			return nil, -1
+		}
+		return nil, pos
+	}
+	ref.name = name
+	pos += len(name)
+
+	if m := rxReferenceSubscript.FindStringSubmatch(data[pos:]); m != nil {
+		ref.sub = &subscript{slice: m[3] != ""}
+		if ref.sub.slice {
+			ref.sub.from, ref.sub.hasFrom = atoi(m[2])
+			ref.sub.to, ref.sub.hasTo = atoi(m[4])
+		} else {
+			ref.sub.from, ref.sub.hasFrom = atoi(m[1])
+		}
+		if ref.sub.hasFrom != (m[1]+m[2] != "") || ref.sub.hasTo != (m[4] != "") {
+			// Number out of range
+			return nil, pos
+		}
+		pos += len(m[0])
+	}
+
+	if ref.length {
+		// Word count takes no operators
+	} else if op := rxReferenceOp.FindString(data[pos:]); op != "" {
+		if (ref.sub != nil || ref.env) && strings.HasSuffix(op, "=") {
+			// Cannot assign to a subscript or environment
+			return nil, pos
+		}
+		ref.op = op
+		pos += len(op)
+		stop := "|}"
+		if op[0] == '/' {
+			stop = "/|}"
+		}
+		// Patterns are evaluated outside of double quotes
+		pattern := strings.IndexByte("#%/", op[0]) >= 0
+		end := scanUnquoted(data, pos, stop, dquo && !pattern)
+		if end < 0 {
+			return nil, -1
+		}
+		ref.word = [2]int{pos, end}
+		ref.repl = [2]int{end, end}
+		pos = end
+		if data[pos] == '/' {
+			if end = scanUnquoted(data, pos+1, "|}", dquo); end < 0 {
+				return nil, -1
+			}
+			ref.repl = [2]int{pos + 1, end}
+			pos = end
+		}
+	}
+
+	if pos < len(data) && data[pos] == '|' {
+		end := strings.IndexByte(data[pos:], '}')
+		if end < 0 {
+			return nil, -1
+		}
+		ref.glue = data[pos+1 : pos+end]
+		pos += end
+	}
+
+	if pos >= len(data) {
+		return nil, -1
+	}
+	if data[pos] != '}' {
+		return nil, pos
+	}
+	return ref, pos
+}
+
+// Returns reference's variable name for messages
+func (ref *reference) String() string {
+	if ref.env {
+		return "env:" + ref.name
+	}
+	return ref.name
+}
+
+func atoi(s string) (int, bool) {
+	if s == "" {
+		return 0, false
+	}
+	i, err := strconv.Atoi(s)
+	return i, err == nil
+}
+
+// Returns words of `val` selected by subscript. Returns nil if an
+// index is out of range.
+func (s *subscript) apply(val []string) []string {
+	if val == nil {
+		return nil
+	}
+	if !s.slice {
+		i := s.from
+		if i < 0 {
+			i += len(val)
+		}
+		if i < 0 || i >= len(val) {
+			return nil
+		}
+		return val[i : i+1]
+	}
+
+	from, to := 0, len(val)
+	if s.hasFrom {
+		from = clampIndex(s.from, len(val))
+	}
+	if s.hasTo {
+		to = clampIndex(s.to, len(val))
+	}
+	if from >= to {
+		return []string{}
+	}
+	return val[from:to]
+}
+
+// Resolves negative index `i` from the end of list of length `n`,
+// and clamps result to range [0, n]
+func clampIndex(i, n int) int {
+	if i < 0 {
+		i += n
+	}
+	if i < 0 {
+		return 0
+	}
+	if i > n {
+		return n
+	}
+	return i
+}
+
+// Returns offset of the first byte in `data` at or after `start`
+// that is one of `stop`, and is not escaped, quoted, or within a
+// nested braced variable reference. If `dquo` is true, scanning starts
+// within double quotes, where single quotes are literal. Returns -1 if
+// there is no such byte.
+func scanUnquoted(data string, start int, stop string, dquo bool) int {
+	depth := 0
+	outer := dquo
+	for i := start; i < len(data); i++ {
+		switch c := data[i]; {
+		case c == '\\':
+			i++
+		case c == '\'' && !dquo:
+			if j := strings.IndexByte(data[i+1:], '\''); j < 0 {
+				return -1
+			} else {
+				i += j + 1
+			}
+		case c == '"':
+			dquo = !dquo
+		case c == '$' && i+1 < len(data) && data[i+1] == '{':
+			depth++
+			i++
+		case c == '}' && depth > 0:
+			depth--
+		case depth == 0 && dquo == outer && strings.IndexByte(stop, c) >= 0:
+			return i
+		}
+	}
+	return -1
+}
+
+// Returns true if variable value `val` is unset or, if `colon` is
+// true, null (contains no non-empty words)
+func isUnset(val []string, colon bool) bool {
+	if val == nil {
+		return true
+	}
+	return colon && strings.Join(val, "") == ""
+}
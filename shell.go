@@ -0,0 +1,86 @@
+package shlike
+
+import "fmt"
+import "io"
+import "regexp"
+import "sort"
+import "strings"
+
+// Shell syntax written by `WriteShell`
+type ShellDialect int
+
+const (
+	POSIXShell ShellDialect = iota // Words of each value are joined into a single string
+	Bash                           // Values are arrays
+)
+
+var rxShellSafe = regexp.MustCompile(`^[A-Za-z0-9_@%+=:,./-]+$`)
+var rxShellName = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+var rxShellAssignment = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*\+?=`)
+
+// Returns `str` quoted as a single word for a POSIX shell. This works
+// like `Escape`, but leaves fewer characters unquoted, as many
+// characters that are safe in configuration (e.g. `;`, `|`, `*`, `~`)
+// are special to the shell. Words that look like an assignment (e.g.
+// `A=b`) are quoted as well, so that they stay literal words at
+// beginning of a command.
+func ShellQuote(str string) string {
+	if rxShellSafe.MatchString(str) && !rxShellAssignment.MatchString(str) {
+		return str
+	}
+	return "'" + strings.Replace(str, "'", "'\\''", -1) + "'"
+}
+
+// Returns shell assignment of `value` to variable `name`: a string of
+// words joined with spaces for `POSIXShell`, or an array for `Bash`.
+// Returns an error if `name` is not a valid shell variable name.
+func ShellAssignment(name string, value []string, dialect ShellDialect) (string, error) {
+	if !rxShellName.MatchString(name) {
+		return "", fmt.Errorf("Invalid shell variable name %#v", name)
+	}
+	if dialect == Bash {
+		quoted := make([]string, len(value))
+		for i, word := range value {
+			quoted[i] = ShellQuote(word)
+		}
+		return fmt.Sprintf("%s=(%s)", name, strings.Join(quoted, " ")), nil
+	}
+	return name + "=" + ShellQuote(strings.Join(value, " ")), nil
+}
+
+// Writes variables of `c` as shell code that sets them when evaluated
+// (e.g. `eval "$(…)"`), in alphabetical order. Exported variables (if
+// `c` implements `Exporter`) are written as exported strings, as
+// shells cannot export arrays.
+func WriteShell(w io.Writer, c Config, dialect ShellDialect) error {
+	exported := map[string]bool{}
+	if exp, ok := asExporter(c); ok {
+		for _, name := range exp.Exported() {
+			exported[name] = true
+		}
+	}
+
+	names := c.Variables()
+	sort.Strings(names)
+	for _, name := range names {
+		val := c.Get(name)
+		if val == nil {
+			continue
+		}
+		var line string
+		var err error
+		if exported[name] {
+			line, err = ShellAssignment(name, val, POSIXShell)
+			line = "export " + line
+		} else {
+			line, err = ShellAssignment(name, val, dialect)
+		}
+		if err != nil {
+			return err
+		}
+		if _, err := fmt.Fprintln(w, line); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,76 @@
+package shlike
+
+import "bytes"
+import "os/exec"
+import "testing"
+
+import . "github.com/smartystreets/goconvey/convey"
+
+func TestShell(t *testing.T) {
+	Convey("ShellQuote", t, func() {
+		So(ShellQuote("foo/bar-1.2:3"), ShouldEqual, "foo/bar-1.2:3")
+		So(ShellQuote(""), ShouldEqual, "''")
+		So(ShellQuote("a;b"), ShouldEqual, "'a;b'")
+		So(ShellQuote("~user"), ShouldEqual, "'~user'")
+		So(ShellQuote("it's"), ShouldEqual, `'it'\''s'`)
+		So(Escape("a;b"), ShouldEqual, "a;b")
+		So(ShellQuote("A=b"), ShouldEqual, "'A=b'")
+		So(ShellQuote("A+=b"), ShouldEqual, "'A+=b'")
+		So(ShellQuote("--name=x"), ShouldEqual, "--name=x")
+	})
+
+	Convey("ShellAssignment", t, func() {
+		line, err := ShellAssignment("FOO", []string{"a", "b c", "*"}, POSIXShell)
+		So(err, ShouldBeNil)
+		So(line, ShouldEqual, "FOO='a b c *'")
+
+		line, err = ShellAssignment("FOO", []string{"a", "b c", "*"}, Bash)
+		So(err, ShouldBeNil)
+		So(line, ShouldEqual, "FOO=(a 'b c' '*')")
+
+		line, err = ShellAssignment("FOO", []string{}, Bash)
+		So(err, ShouldBeNil)
+		So(line, ShouldEqual, "FOO=()")
+
+		_, err = ShellAssignment("zażółć", []string{"x"}, POSIXShell)
+		So(err, ShouldNotBeNil)
+	})
+
+	Convey("WriteShell", t, func() {
+		cfg := NewConfig()
+		So(cfg.Eval(`
+export HOST = db.example.com
+FLAGS = -v "--name=it's me" '$HOME'
+EMPTY =
+`), ShouldBeNil)
+
+		var buf bytes.Buffer
+		So(WriteShell(&buf, cfg, POSIXShell), ShouldBeNil)
+		So(buf.String(), ShouldEqual, "EMPTY=''\nFLAGS='-v --name=it'\\''s me $HOME'\nexport HOST=db.example.com\n")
+
+		buf.Reset()
+		So(WriteShell(&buf, cfg, Bash), ShouldBeNil)
+		So(buf.String(), ShouldEqual, "EMPTY=()\nFLAGS=(-v '--name=it'\\''s me' '$HOME')\nexport HOST=db.example.com\n")
+
+		Convey("evaluates in bash to the same values", func() {
+			bash, err := exec.LookPath("bash")
+			if err != nil {
+				return
+			}
+			out, err := exec.Command(bash, "-c", buf.String()+`printf '<%s>' "${FLAGS[@]}" "${#EMPTY[@]}"; printenv HOST`).Output()
+			So(err, ShouldBeNil)
+			So(string(out), ShouldEqual, "<-v><--name=it's me><$HOME><0>db.example.com\n")
+		})
+
+		Convey("keeps exports of wrapped config", func() {
+			buf.Reset()
+			So(WriteShell(&buf, NewProvenanceConfig(cfg), POSIXShell), ShouldBeNil)
+			So(buf.String(), ShouldContainSubstring, "\nexport HOST=db.example.com\n")
+		})
+
+		Convey("fails for invalid names", func() {
+			cfg.Set("zażółć", "x")
+			So(WriteShell(&buf, cfg, Bash), ShouldNotBeNil)
+		})
+	})
+}
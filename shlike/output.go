@@ -0,0 +1,314 @@
+package main
+
+import "bytes"
+import "encoding/json"
+import "fmt"
+import "io"
+import "regexp"
+import "strings"
+
+import "github.com/3ofcoins/shlike"
+
+// Prints evaluation results in an output format. Methods return an
+// error if the format cannot represent the data.
+type formatter interface {
+	config(cfg *shlike.SimpleConfig) error
+	variables(vars map[string][]string) error
+	variable(name string, value []string) error
+	lines(lines [][]string) error
+}
+
+var formats = map[string]func(io.Writer) formatter{
+	"json": func(w io.Writer) formatter { return jsonFormatter{w} },
+	"yaml": func(w io.Writer) formatter { return yamlFormatter{w} },
+	"toml": func(w io.Writer) formatter { return tomlFormatter{w} },
+	"env":  func(w io.Writer) formatter { return envFormatter{w, "\n"} },
+	"nul":  func(w io.Writer) formatter { return envFormatter{w, "\x00"} },
+	"sh":   func(w io.Writer) formatter { return shFormatter{w, shlike.POSIXShell} },
+	"bash": func(w io.Writer) formatter { return shFormatter{w, shlike.Bash} },
+}
+
+// Returns formatter writing format `name` to `w`
+func newFormatter(name string, w io.Writer) (formatter, error) {
+	newf, ok := formats[name]
+	if !ok {
+		return nil, fmt.Errorf("unknown format %#v", name)
+	}
+	return newf(w), nil
+}
+
+func unsupported(format, what string) error {
+	return fmt.Errorf("%s format cannot represent %s", format, what)
+}
+
+// Returns `str` as a JSON string, which is also a valid YAML and TOML
+// basic string
+func quote(str string) string {
+	var buf bytes.Buffer
+	enc := json.NewEncoder(&buf)
+	enc.SetEscapeHTML(false)
+	enc.Encode(str)
+	return strings.TrimSuffix(buf.String(), "\n")
+}
+
+// Returns `words` as an inline JSON / TOML array
+func inlineList(words []string) string {
+	quoted := make([]string, len(words))
+	for i, word := range words {
+		quoted[i] = quote(word)
+	}
+	return "[" + strings.Join(quoted, ", ") + "]"
+}
+
+type jsonFormatter struct{ w io.Writer }
+
+func (f jsonFormatter) print(v interface{}) error {
+	enc := json.NewEncoder(f.w)
+	enc.SetEscapeHTML(false)
+	enc.SetIndent("", "  ")
+	return enc.Encode(v)
+}
+
+func (f jsonFormatter) config(cfg *shlike.SimpleConfig) error    { return f.print(cfg) }
+func (f jsonFormatter) variables(vars map[string][]string) error { return f.print(vars) }
+func (f jsonFormatter) variable(_ string, value []string) error  { return f.print(value) }
+func (f jsonFormatter) lines(lines [][]string) error             { return f.print(lines) }
+
+type yamlFormatter struct{ w io.Writer }
+
+var rxYAMLKey = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+var rxYAMLReserved = regexp.MustCompile(`^(?i:y|n|yes|no|true|false|on|off|null)$`)
+
+func yamlKey(name string) string {
+	if rxYAMLKey.MatchString(name) && !rxYAMLReserved.MatchString(name) {
+		return name
+	}
+	return quote(name)
+}
+
+// Returns `words` as a YAML block sequence indented by `indent`, or an
+// empty flow sequence
+func yamlList(indent string, words []string) string {
+	if len(words) == 0 {
+		return " []"
+	}
+	var buf strings.Builder
+	for _, word := range words {
+		buf.WriteString("\n" + indent + "- " + quote(word))
+	}
+	return buf.String()
+}
+
+func (f yamlFormatter) variablesYAML(indent string, vars map[string][]string) string {
+	var buf strings.Builder
+	for _, name := range sortedNames(vars) {
+		fmt.Fprintf(&buf, "%s%s:%s\n", indent, yamlKey(name), yamlList(indent+"  ", vars[name]))
+	}
+	return buf.String()
+}
+
+func (f yamlFormatter) config(cfg *shlike.SimpleConfig) error {
+	out := "Vars:"
+	if len(cfg.Vars) == 0 {
+		out += " {}\n"
+	} else {
+		out += "\n" + f.variablesYAML("  ", cfg.Vars)
+	}
+	out += "Lines:" + f.linesBlock("  ", cfg.Lines)
+	if exported := cfg.Exported(); len(exported) > 0 {
+		out += "Exports:" + yamlList("  ", exported) + "\n"
+	}
+	_, err := io.WriteString(f.w, out)
+	return err
+}
+
+func (f yamlFormatter) variables(vars map[string][]string) error {
+	out := f.variablesYAML("", vars)
+	if out == "" {
+		out = "{}\n"
+	}
+	_, err := io.WriteString(f.w, out)
+	return err
+}
+
+func (f yamlFormatter) variable(_ string, value []string) error {
+	_, err := io.WriteString(f.w, strings.TrimLeft(yamlList("", value), " \n")+"\n")
+	return err
+}
+
+func (f yamlFormatter) lines(lines [][]string) error {
+	_, err := io.WriteString(f.w, strings.TrimLeft(f.linesBlock("", lines), " \n"))
+	return err
+}
+
+// Returns `lines` as a YAML block sequence of sequences, starting
+// with a line break, or an empty flow sequence
+func (f yamlFormatter) linesBlock(indent string, lines [][]string) string {
+	if len(lines) == 0 {
+		return " []\n"
+	}
+	var buf strings.Builder
+	for _, line := range lines {
+		buf.WriteString("\n" + indent + "-")
+		if len(line) == 0 {
+			buf.WriteString(" []")
+			continue
+		}
+		for i, word := range line {
+			if i == 0 {
+				buf.WriteString(" - " + quote(word))
+			} else {
+				buf.WriteString("\n" + indent + "  - " + quote(word))
+			}
+		}
+	}
+	return buf.String() + "\n"
+}
+
+type tomlFormatter struct{ w io.Writer }
+
+var rxTOMLKey = regexp.MustCompile(`^[A-Za-z0-9_-]+$`)
+
+func tomlKey(name string) string {
+	if rxTOMLKey.MatchString(name) {
+		return name
+	}
+	return quote(name)
+}
+
+func (f tomlFormatter) variablesTOML(vars map[string][]string) string {
+	var buf strings.Builder
+	for _, name := range sortedNames(vars) {
+		fmt.Fprintf(&buf, "%s = %s\n", tomlKey(name), inlineList(vars[name]))
+	}
+	return buf.String()
+}
+
+func (f tomlFormatter) linesTOML(lines [][]string) string {
+	if len(lines) == 0 {
+		return "Lines = []\n"
+	}
+	var buf strings.Builder
+	buf.WriteString("Lines = [\n")
+	for _, line := range lines {
+		buf.WriteString("  " + inlineList(line) + ",\n")
+	}
+	buf.WriteString("]\n")
+	return buf.String()
+}
+
+func (f tomlFormatter) config(cfg *shlike.SimpleConfig) error {
+	out := f.linesTOML(cfg.Lines)
+	if exported := cfg.Exported(); len(exported) > 0 {
+		out += "Exports = " + inlineList(exported) + "\n"
+	}
+	out += "\n[Vars]\n" + f.variablesTOML(cfg.Vars)
+	_, err := io.WriteString(f.w, out)
+	return err
+}
+
+func (f tomlFormatter) variables(vars map[string][]string) error {
+	_, err := io.WriteString(f.w, f.variablesTOML(vars))
+	return err
+}
+
+func (f tomlFormatter) variable(name string, value []string) error {
+	_, err := fmt.Fprintf(f.w, "%s = %s\n", tomlKey(name), inlineList(value))
+	return err
+}
+
+func (f tomlFormatter) lines(lines [][]string) error {
+	_, err := io.WriteString(f.w, f.linesTOML(lines))
+	return err
+}
+
+// Prints variables as `NAME=value` records, with words joined by
+// spaces. Records are terminated by `sep` (a line break for env
+// files, NUL for NUL-delimited output). For a single variable, each
+// word is a separate record.
+type envFormatter struct {
+	w   io.Writer
+	sep string
+}
+
+func (f envFormatter) name() string {
+	if f.sep == "\n" {
+		return "env"
+	}
+	return "nul"
+}
+
+func (f envFormatter) record(rec string) error {
+	if strings.Contains(rec, f.sep) {
+		return fmt.Errorf("%s format cannot represent %#v", f.name(), rec)
+	}
+	_, err := io.WriteString(f.w, rec+f.sep)
+	return err
+}
+
+func (f envFormatter) config(*shlike.SimpleConfig) error {
+	return unsupported(f.name(), "whole configuration")
+}
+
+func (f envFormatter) variables(vars map[string][]string) error {
+	for _, name := range sortedNames(vars) {
+		if err := f.record(name + "=" + strings.Join(vars[name], " ")); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (f envFormatter) variable(_ string, value []string) error {
+	for _, word := range value {
+		if err := f.record(word); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (f envFormatter) lines([][]string) error {
+	return unsupported(f.name(), "lines")
+}
+
+// Prints shell code that can be evaluated by a POSIX shell or by bash
+type shFormatter struct {
+	w       io.Writer
+	dialect shlike.ShellDialect
+}
+
+func (f shFormatter) config(cfg *shlike.SimpleConfig) error {
+	return shlike.WriteShell(f.w, cfg, f.dialect)
+}
+
+func (f shFormatter) variables(vars map[string][]string) error {
+	for _, name := range sortedNames(vars) {
+		if err := f.variable(name, vars[name]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (f shFormatter) variable(name string, value []string) error {
+	line, err := shlike.ShellAssignment(name, value, f.dialect)
+	if err != nil {
+		return err
+	}
+	_, err = fmt.Fprintln(f.w, line)
+	return err
+}
+
+func (f shFormatter) lines(lines [][]string) error {
+	for _, line := range lines {
+		quoted := make([]string, len(line))
+		for i, word := range line {
+			quoted[i] = shlike.ShellQuote(word)
+		}
+		if _, err := fmt.Fprintln(f.w, strings.Join(quoted, " ")); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,100 @@
+package main
+
+import "bytes"
+import "testing"
+
+import . "github.com/smartystreets/goconvey/convey"
+
+var testVars = map[string][]string{
+	"A":   {"it's", `a"b\c`, "<&>"},
+	"E":   {},
+	"yes": {"x"},
+}
+
+var testLines = [][]string{{"run", "a b"}, {}}
+
+func TestFormatters(t *testing.T) {
+	Convey("Formatters", t, func() {
+		for _, tc := range []struct {
+			format string
+			call   func(formatter) error
+			out    string
+		}{
+			{"json", func(f formatter) error { return f.variables(testVars) },
+				"{\n  \"A\": [\n    \"it's\",\n    \"a\\\"b\\\\c\",\n    \"<&>\"\n  ],\n  \"E\": [],\n  \"yes\": [\n    \"x\"\n  ]\n}\n"},
+			{"json", func(f formatter) error { return f.lines(testLines) },
+				"[\n  [\n    \"run\",\n    \"a b\"\n  ],\n  []\n]\n"},
+			{"json", func(f formatter) error { return f.variable("E", []string{}) },
+				"[]\n"},
+			{"yaml", func(f formatter) error { return f.variables(testVars) },
+				"A:\n  - \"it's\"\n  - \"a\\\"b\\\\c\"\n  - \"<&>\"\nE: []\n\"yes\":\n  - \"x\"\n"},
+			{"yaml", func(f formatter) error { return f.variables(map[string][]string{}) },
+				"{}\n"},
+			{"yaml", func(f formatter) error { return f.lines(testLines) },
+				"- - \"run\"\n  - \"a b\"\n- []\n"},
+			{"yaml", func(f formatter) error { return f.lines(nil) },
+				"[]\n"},
+			{"yaml", func(f formatter) error { return f.variable("E", []string{}) },
+				"[]\n"},
+			{"toml", func(f formatter) error { return f.variables(testVars) },
+				"A = [\"it's\", \"a\\\"b\\\\c\", \"<&>\"]\nE = []\nyes = [\"x\"]\n"},
+			{"toml", func(f formatter) error { return f.lines(testLines) },
+				"Lines = [\n  [\"run\", \"a b\"],\n  [],\n]\n"},
+			{"toml", func(f formatter) error { return f.lines(nil) },
+				"Lines = []\n"},
+			{"toml", func(f formatter) error { return f.variable("a.b", []string{}) },
+				"\"a.b\" = []\n"},
+			{"env", func(f formatter) error { return f.variables(testVars) },
+				"A=it's a\"b\\c <&>\nE=\nyes=x\n"},
+			{"env", func(f formatter) error { return f.variable("A", testVars["A"]) },
+				"it's\na\"b\\c\n<&>\n"},
+			{"nul", func(f formatter) error { return f.variables(testVars) },
+				"A=it's a\"b\\c <&>\x00E=\x00yes=x\x00"},
+			{"sh", func(f formatter) error { return f.variables(testVars) },
+				"A='it'\\''s a\"b\\c <&>'\nE=''\nyes=x\n"},
+			{"sh", func(f formatter) error { return f.lines(testLines) },
+				"run 'a b'\n\n"},
+			{"sh", func(f formatter) error { return f.lines([][]string{{"A=b", "c", "d"}}) },
+				"'A=b' c d\n"},
+			{"bash", func(f formatter) error { return f.variables(testVars) },
+				"A=('it'\\''s' 'a\"b\\c' '<&>')\nE=()\nyes=(x)\n"},
+		} {
+			var buf bytes.Buffer
+			f, err := newFormatter(tc.format, &buf)
+			So(err, ShouldBeNil)
+			So(tc.call(f), ShouldBeNil)
+			So(buf.String(), ShouldEqual, tc.out)
+		}
+	})
+
+	Convey("Formatter errors", t, func() {
+		for _, tc := range []struct {
+			format string
+			call   func(formatter) error
+			err    string
+		}{
+			{"env", func(f formatter) error { return f.config(nil) },
+				"env format cannot represent whole configuration"},
+			{"env", func(f formatter) error { return f.lines(testLines) },
+				"env format cannot represent lines"},
+			{"nul", func(f formatter) error { return f.lines(testLines) },
+				"nul format cannot represent lines"},
+			{"env", func(f formatter) error { return f.variable("A", []string{"a\nb"}) },
+				`env format cannot represent "a\nb"`},
+			{"nul", func(f formatter) error { return f.variables(map[string][]string{"A": {"a\x00"}}) },
+				`nul format cannot represent "A=a\x00"`},
+			{"sh", func(f formatter) error { return f.variable("a.b", nil) },
+				`Invalid shell variable name "a.b"`},
+		} {
+			var buf bytes.Buffer
+			f, err := newFormatter(tc.format, &buf)
+			So(err, ShouldBeNil)
+			err = tc.call(f)
+			So(err, ShouldNotBeNil)
+			So(err.Error(), ShouldEqual, tc.err)
+		}
+
+		_, err := newFormatter("xml", nil)
+		So(err.Error(), ShouldEqual, `unknown format "xml"`)
+	})
+}
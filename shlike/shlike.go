@@ -0,0 +1,240 @@
+// Command shlike evaluates shlike configuration files and prints the
+// result.
+//
+// Usage:
+//
+//	shlike command [flags] [file ...]
+//
+// Commands are:
+//
+//	eval     print variables and lines
+//	export   print shell code setting the variables
+//	get VAR  print value of a variable
+//	lines    print lines
+//	vars     print variables
+//
+// Files are loaded in order into a single configuration; without
+// files, configuration is read from standard input. Flags can be
+// given between files, and variables set with -s and -a flags are
+// applied in order of arguments: files that follow an assignment can
+// reference (or reassign) the variable, and an assignment that
+// follows a file overrides the file's value. Exit status is 1 on
+// errors (including `get` of an unset variable), and 2 on usage
+// errors (including an output format that the command does not
+// support).
+package main
+
+import "flag"
+import "fmt"
+import "io"
+import "os"
+import "sort"
+import "strings"
+
+import "github.com/3ofcoins/shlike"
+
+// An input of the configuration: a file to load, or a variable
+// assignment given with a flag
+type input struct {
+	file        string
+	appendValue bool
+	name, value string
+}
+
+type inputs []input
+
+type assignmentFlag struct {
+	list        *inputs
+	appendValue bool
+}
+
+func (f assignmentFlag) String() string {
+	return ""
+}
+
+func (f assignmentFlag) Set(value string) error {
+	splut := strings.SplitN(value, "=", 2)
+	if len(splut) != 2 {
+		return fmt.Errorf("expected NAME=value, got %#v", value)
+	}
+	*f.list = append(*f.list, input{"", f.appendValue, splut[0], splut[1]})
+	return nil
+}
+
+// Parses `arguments` with flags of `fs` given between files, which
+// are added to `in`. Returns the first `nargs` positional arguments,
+// or false if there are fewer of them.
+func parseArgs(fs *flag.FlagSet, arguments []string, nargs int, in *inputs) ([]string, bool) {
+	if err := fs.Parse(arguments); err != nil || fs.NArg() < nargs {
+		return nil, false
+	}
+	args := fs.Args()[:nargs]
+	if err := fs.Parse(fs.Args()[nargs:]); err != nil {
+		return nil, false
+	}
+	rest := fs.Args()
+	for len(rest) > 0 {
+		*in = append(*in, input{file: rest[0]})
+		if err := fs.Parse(rest[1:]); err != nil {
+			return nil, false
+		}
+		rest = fs.Args()
+	}
+	return args, true
+}
+
+type command struct {
+	name    string
+	args    string // Positional arguments before files
+	nargs   int
+	formats []string // Supported formats, the first one is default
+	help    string
+	run     func(cfg *shlike.SimpleConfig, args []string, f formatter) error
+}
+
+var commands = []command{
+	{"eval", "", 0, formatNames("env", "nul", "sh", "bash"), "print variables and lines", func(cfg *shlike.SimpleConfig, _ []string, f formatter) error {
+		return f.config(cfg)
+	}},
+	{"export", "", 0, []string{"sh", "bash"}, "print shell code setting the variables", func(cfg *shlike.SimpleConfig, _ []string, f formatter) error {
+		return f.config(cfg)
+	}},
+	{"get", "VAR", 1, nil, "print value of a variable", func(cfg *shlike.SimpleConfig, args []string, f formatter) error {
+		value := cfg.Get(args[0])
+		if value == nil {
+			return fmt.Errorf("variable %s is not set", args[0])
+		}
+		return f.variable(args[0], value)
+	}},
+	{"lines", "", 0, formatNames("env", "nul"), "print lines", func(cfg *shlike.SimpleConfig, _ []string, f formatter) error {
+		return f.lines(cfg.Lines)
+	}},
+	{"vars", "", 0, nil, "print variables", func(cfg *shlike.SimpleConfig, _ []string, f formatter) error {
+		return f.variables(cfg.Vars)
+	}},
+}
+
+func usage() {
+	fmt.Fprintln(os.Stderr, "usage: shlike command [flags] [file ...]\n\nCommands:")
+	for _, cmd := range commands {
+		fmt.Fprintf(os.Stderr, "  %-10s %s\n", strings.TrimSpace(cmd.name+" "+cmd.args), cmd.help)
+	}
+	fmt.Fprintln(os.Stderr, "\nRun 'shlike command -h' for command's flags.")
+}
+
+func fail(err error) {
+	fmt.Fprintf(os.Stderr, "shlike: %s\n", err)
+	os.Exit(1)
+}
+
+func main() {
+	if len(os.Args) < 2 {
+		usage()
+		os.Exit(2)
+	}
+	var cmd *command
+	for i := range commands {
+		if commands[i].name == os.Args[1] {
+			cmd = &commands[i]
+		}
+	}
+	if cmd == nil {
+		if os.Args[1] != "-h" && os.Args[1] != "-help" && os.Args[1] != "help" {
+			fmt.Fprintf(os.Stderr, "shlike: unknown command %#v\n", os.Args[1])
+		}
+		usage()
+		os.Exit(2)
+	}
+
+	var in inputs
+	fs := flag.NewFlagSet("shlike "+cmd.name, flag.ExitOnError)
+	names := cmd.formats
+	if names == nil {
+		names = formatNames()
+	}
+	format := fs.String("f", names[0], "output `format`: "+strings.Join(names, ", "))
+	fs.Var(assignmentFlag{&in, false}, "s", "set variable before loading following files (`NAME=value`, repeatable)")
+	fs.Var(assignmentFlag{&in, true}, "a", "append to variable before loading following files (`NAME=value`, repeatable)")
+	fs.Usage = func() {
+		fmt.Fprintf(os.Stderr, "usage: shlike %s [flags] %s\n", cmd.name, strings.TrimSpace(cmd.args+" [file ...]"))
+		fmt.Fprintln(os.Stderr, "Flags can be given between files, and are applied in order.")
+		fs.PrintDefaults()
+	}
+	args, ok := parseArgs(fs, os.Args[2:], cmd.nargs, &in)
+	if !ok {
+		fs.Usage()
+		os.Exit(2)
+	}
+
+	f, err := newFormatter(*format, os.Stdout)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "shlike: unknown format %#v\n", *format)
+		os.Exit(2)
+	}
+	if !contains(names, *format) {
+		fmt.Fprintf(os.Stderr, "shlike: %s does not support format %#v\n", cmd.name, *format)
+		os.Exit(2)
+	}
+
+	cfg := shlike.NewConfig()
+	if err := load(cfg, in, os.Stdin); err != nil {
+		fail(err)
+	}
+	if err := cmd.run(cfg, args, f); err != nil {
+		fail(err)
+	}
+}
+
+// Loads files and applies assignments of `in` into `cfg`, in order.
+// If there are no files, `stdin` is loaded after the assignments.
+func load(cfg *shlike.SimpleConfig, in inputs, stdin io.Reader) error {
+	files := 0
+	for _, i := range in {
+		switch {
+		case i.file != "":
+			files++
+			if err := cfg.Load(i.file); err != nil {
+				return err
+			}
+		case i.appendValue:
+			cfg.Append(i.name, i.value)
+		default:
+			cfg.Set(i.name, i.value)
+		}
+	}
+	if files == 0 {
+		return shlike.LoadReader(cfg, "<standard input>", stdin)
+	}
+	return nil
+}
+
+func contains(list []string, str string) bool {
+	for _, elt := range list {
+		if elt == str {
+			return true
+		}
+	}
+	return false
+}
+
+// Returns names of all formats except `exclude`, default (JSON) first
+func formatNames(exclude ...string) []string {
+	names := make([]string, 0, len(formats))
+	for name := range formats {
+		if name != "json" && !contains(exclude, name) {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	return append([]string{"json"}, names...)
+}
+
+// Returns sorted names of variables
+func sortedNames(vars map[string][]string) []string {
+	names := make([]string, 0, len(vars))
+	for name := range vars {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
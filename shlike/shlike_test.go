@@ -0,0 +1,70 @@
+package main
+
+import "flag"
+import "io/ioutil"
+import "path/filepath"
+import "strings"
+import "testing"
+
+import . "github.com/smartystreets/goconvey/convey"
+
+import "github.com/3ofcoins/shlike"
+
+func TestArguments(t *testing.T) {
+	Convey("Assignments and files are applied in order", t, func() {
+		dir := t.TempDir()
+		file := filepath.Join(dir, "app.conf")
+		So(ioutil.WriteFile(file, []byte("URL = \"http://$HOST\"\nPORT = 80\n"), 0644), ShouldBeNil)
+
+		var in inputs
+		fs := flag.NewFlagSet("test", flag.ContinueOnError)
+		fs.Var(assignmentFlag{&in, false}, "s", "")
+		fs.Var(assignmentFlag{&in, true}, "a", "")
+		args, ok := parseArgs(fs, []string{"-s", "HOST=example.com", "VAR", file, "-s", "PORT=8080", "-a", "PORT=8081"}, 1, &in)
+		So(ok, ShouldBeTrue)
+		So(args, ShouldResemble, []string{"VAR"})
+		So(in, ShouldHaveLength, 4)
+
+		cfg := shlike.NewConfig()
+		So(load(cfg, in, strings.NewReader("'not loaded")), ShouldBeNil)
+		So(cfg.Get("URL"), ShouldResemble, []string{"http://example.com"})
+		So(cfg.Get("PORT"), ShouldResemble, []string{"8080", "8081"})
+
+		Convey("without files, standard input is loaded last", func() {
+			cfg := shlike.NewConfig()
+			So(load(cfg, in[:1], strings.NewReader(`URL = "http://$HOST"`)), ShouldBeNil)
+			So(cfg.Get("URL"), ShouldResemble, []string{"http://example.com"})
+		})
+
+		Convey("flags between positional arguments and files", func() {
+			var in inputs
+			fs := flag.NewFlagSet("test", flag.ContinueOnError)
+			format := fs.String("f", "json", "")
+			fs.Var(assignmentFlag{&in, false}, "s", "")
+			args, ok := parseArgs(fs, []string{"VAR", "-f", "bash", "-s", "HOST=example.com", file}, 1, &in)
+			So(ok, ShouldBeTrue)
+			So(args, ShouldResemble, []string{"VAR"})
+			So(*format, ShouldEqual, "bash")
+			So(in, ShouldResemble, inputs{{"", false, "HOST", "example.com"}, {file: file}})
+		})
+
+		Convey("missing positional arguments", func() {
+			_, ok := parseArgs(fs, []string{"-s", "A=b"}, 1, &in)
+			So(ok, ShouldBeFalse)
+		})
+	})
+}
+
+func TestCommandFormats(t *testing.T) {
+	Convey("Commands list only formats that can represent their output", t, func() {
+		supported := map[string][]string{}
+		for _, cmd := range commands {
+			supported[cmd.name] = cmd.formats
+		}
+		So(formatNames(), ShouldResemble, []string{"json", "bash", "env", "nul", "sh", "toml", "yaml"})
+		So(supported["eval"], ShouldResemble, []string{"json", "toml", "yaml"})
+		So(supported["lines"], ShouldResemble, []string{"json", "bash", "sh", "toml", "yaml"})
+		So(supported["export"], ShouldResemble, []string{"sh", "bash"})
+		So(supported["vars"], ShouldBeNil)
+	})
+}
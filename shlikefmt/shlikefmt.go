@@ -0,0 +1,134 @@
+// Command shlikefmt formats shlike configuration files.
+//
+// Usage:
+//
+//	shlikefmt [flags] [path ...]
+//
+// Without paths, it formats standard input. By default, formatted
+// source is printed to standard output.
+package main
+
+import "bytes"
+import "flag"
+import "fmt"
+import "io/ioutil"
+import "os"
+import "os/exec"
+
+import "github.com/3ofcoins/shlike"
+
+var list = flag.Bool("l", false, "list files whose formatting differs from shlikefmt's")
+var write = flag.Bool("w", false, "write result to (source) file instead of stdout")
+var doDiff = flag.Bool("d", false, "display diffs instead of rewriting files")
+
+var exitCode = 0
+
+func report(err error) {
+	fmt.Fprintln(os.Stderr, err)
+	exitCode = 2
+}
+
+func processFile(name string, in []byte, stdin bool) error {
+	out, err := shlike.Format(name, in)
+	if err != nil {
+		return err
+	}
+	if bytes.Equal(in, out) && (*list || *write || *doDiff) {
+		return nil
+	}
+	if *list {
+		fmt.Println(name)
+	}
+	if *write && !stdin {
+		fi, err := os.Stat(name)
+		if err != nil {
+			return err
+		}
+		if err := ioutil.WriteFile(name, out, fi.Mode().Perm()); err != nil {
+			return err
+		}
+	}
+	if *doDiff {
+		d, err := diff(name, in, out)
+		if err != nil {
+			return fmt.Errorf("computing diff: %s", err)
+		}
+		fmt.Printf("diff -u %s.orig %s\n", name, name)
+		os.Stdout.Write(d)
+	}
+	if !*list && !*write && !*doDiff {
+		os.Stdout.Write(out)
+	}
+	return nil
+}
+
+// Returns unified diff of `b1` and `b2`, computed by the system's
+// `diff` command
+func diff(name string, b1, b2 []byte) ([]byte, error) {
+	f1, err := writeTemp(b1)
+	if err != nil {
+		return nil, err
+	}
+	defer os.Remove(f1)
+	f2, err := writeTemp(b2)
+	if err != nil {
+		return nil, err
+	}
+	defer os.Remove(f2)
+
+	data, err := exec.Command("diff", "-u", "--label", name+".orig", "--label", name, f1, f2).Output()
+	if len(data) > 0 {
+		// diff exits with a non-zero status when the files don't match
+		err = nil
+	}
+	return data, err
+}
+
+func writeTemp(data []byte) (string, error) {
+	f, err := ioutil.TempFile("", "shlikefmt")
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+	if _, err := f.Write(data); err != nil {
+		os.Remove(f.Name())
+		return "", err
+	}
+	return f.Name(), nil
+}
+
+func usage() {
+	fmt.Fprintln(os.Stderr, "usage: shlikefmt [flags] [path ...]")
+	flag.PrintDefaults()
+}
+
+func main() {
+	flag.Usage = usage
+	flag.Parse()
+
+	if flag.NArg() == 0 {
+		if *write {
+			fmt.Fprintln(os.Stderr, "error: cannot use -w with standard input")
+			os.Exit(2)
+		}
+		in, err := ioutil.ReadAll(os.Stdin)
+		if err == nil {
+			err = processFile("<standard input>", in, true)
+		}
+		if err != nil {
+			report(err)
+		}
+		os.Exit(exitCode)
+	}
+
+	for _, path := range flag.Args() {
+		in, err := ioutil.ReadFile(path)
+		if err == nil {
+			err = processFile(path, in, false)
+		}
+		if err != nil {
+			report(err)
+		}
+	}
+	os.Exit(exitCode)
+}
@@ -0,0 +1,81 @@
+// Command shlikelint reports problems in shlike configuration files.
+//
+// Usage:
+//
+//	shlikelint [flags] path ...
+//
+// Each issue is printed as `file:line:col: message (rule)`. Exit status
+// is 1 if any issues were found, and 2 if a file could not be checked.
+package main
+
+import "encoding/json"
+import "flag"
+import "fmt"
+import "os"
+import "strings"
+
+import "github.com/3ofcoins/shlike"
+
+var asJSON = flag.Bool("json", false, "print issues as a JSON array")
+var used = flag.String("used", "", "comma-separated `names` of variables read by the application")
+var include = flag.String("I", "", "comma-separated include search path `dirs`")
+var disable = flag.String("disable", "", "comma-separated `rules` not to report")
+
+func split(list string) []string {
+	if list == "" {
+		return nil
+	}
+	return strings.Split(list, ",")
+}
+
+func usage() {
+	fmt.Fprintln(os.Stderr, "usage: shlikelint [flags] path ...")
+	flag.PrintDefaults()
+}
+
+func main() {
+	flag.Usage = usage
+	flag.Parse()
+	if flag.NArg() == 0 {
+		usage()
+		os.Exit(2)
+	}
+
+	disabled := map[string]bool{}
+	for _, rule := range split(*disable) {
+		disabled[rule] = true
+	}
+
+	exitCode := 0
+	issues := []shlike.Issue{}
+	for _, path := range flag.Args() {
+		found, err := shlike.Lint(path, split(*used), shlike.IncludePath(split(*include)...))
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			exitCode = 2
+			continue
+		}
+		for _, issue := range found {
+			if !disabled[issue.Rule] {
+				issues = append(issues, issue)
+			}
+		}
+	}
+
+	if *asJSON {
+		if data, err := json.MarshalIndent(issues, "", "  "); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(2)
+		} else {
+			fmt.Println(string(data))
+		}
+	} else {
+		for _, issue := range issues {
+			fmt.Println(issue)
+		}
+	}
+	if len(issues) > 0 && exitCode == 0 {
+		exitCode = 1
+	}
+	os.Exit(exitCode)
+}
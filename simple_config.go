@@ -1,16 +1,18 @@
 package shlike
 
 import "io/ioutil"
+import "sort"
 
-// An implementation of `Config` interface.
+// An implementation of `Config` and `Exporter` interfaces.
 type SimpleConfig struct {
-	Vars  map[string][]string // Variable values
-	Lines [][]string          // Evaluated lines
+	Vars    map[string][]string // Variable values
+	Lines   [][]string          // Evaluated lines
+	Exports map[string]bool     `json:",omitempty"` // Exported variable names
 }
 
 // Returns new config object
 func NewConfig() *SimpleConfig {
-	return &SimpleConfig{map[string][]string{}, [][]string{}}
+	return &SimpleConfig{map[string][]string{}, [][]string{}, map[string]bool{}}
 }
 
 func (c *SimpleConfig) ReceiveLine(words []string) {
@@ -34,6 +36,7 @@ func (c *SimpleConfig) Get(variable string) []string {
 
 func (c *SimpleConfig) Unset(variable string) {
 	delete(c.Vars, variable)
+	delete(c.Exports, variable)
 }
 
 func (c *SimpleConfig) Variables() []string {
@@ -44,6 +47,24 @@ func (c *SimpleConfig) Variables() []string {
 	return rv
 }
 
+func (c *SimpleConfig) Export(names ...string) {
+	if c.Exports == nil {
+		c.Exports = map[string]bool{}
+	}
+	for _, name := range names {
+		c.Exports[name] = true
+	}
+}
+
+func (c *SimpleConfig) Exported() []string {
+	rv := make([]string, 0, len(c.Exports))
+	for name := range c.Exports {
+		rv = append(rv, name)
+	}
+	sort.Strings(rv)
+	return rv
+}
+
 func (c *SimpleConfig) Length() int {
 	return len(c.Lines)
 }
@@ -67,13 +88,13 @@ func (c *SimpleConfig) Iter() <-chan []string {
 }
 
 // Evaluates `source` configuration string
-func (c *SimpleConfig) Eval(source string) error {
-	return EvalInto(c, source)
+func (c *SimpleConfig) Eval(source string, opts ...Option) error {
+	return EvalInto(c, source, opts...)
 }
 
 // Loads configuration from `path`
-func (c *SimpleConfig) Load(path string) error {
-	return LoadInto(c, path)
+func (c *SimpleConfig) Load(path string, opts ...Option) error {
+	return LoadInto(c, path, opts...)
 }
 
 // Serializes configuration into a loadable string